@@ -5,10 +5,13 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
 )
 
+// AnimTextModel scrolls text through width columns. It steps a rune at a
+// time, so that wide characters are never split.
 type AnimTextModel struct {
-	text   string
+	text   []rune
 	offset int
 	width  int
 	id     string
@@ -31,20 +34,15 @@ func (m AnimTextModel) UpdateAnimText(msg tea.Msg) (AnimTextModel, tea.Cmd) {
 }
 
 func (m AnimTextModel) ViewAnimText() string {
-	left := m.offset
-	right := (left + m.width) % len(m.text)
-
-	if left < right {
-		return m.text[left:right]
-	} else {
-		return m.text[left:] + m.text[:right]
-	}
+	rotated := string(m.text[m.offset:]) + string(m.text[:m.offset])
+	// A wide character that does not fit leaves a column empty.
+	return runewidth.FillRight(runewidth.Truncate(rotated, m.width, ""), m.width)
 }
 
 func NewAnimText(t string, id string, opts ...AnimTextModelOpt) AnimTextModel {
 	m := AnimTextModel{
-		text:   t + strings.Repeat(" ", 4),
-		width:  listWidth - 4,
+		text:   []rune(t + strings.Repeat(" ", 4)),
+		width:  runewidth.StringWidth(t),
 		offset: 0,
 		id:     id,
 	}
@@ -6,14 +6,17 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,27 +24,65 @@ import (
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 
 	"golang.org/x/oauth2"
-
-	"github.com/zmb3/spotify/v2"
 )
 
-const redirectURI = "http://localhost:21112/callback"
+const (
+	defaultRedirectHost = "localhost"
+	defaultRedirectPort = 21112
+	defaultAPIURL       = "https://api.spotify.com/v1/"
+	defaultAccountsURL  = "https://accounts.spotify.com"
+
+	// spotifyauth has no constant for this scope.
+	scopeUserReadPlaybackPosition = "user-read-playback-position"
+)
 
 var (
-	auth = spotifyauth.New(spotifyauth.WithRedirectURL(redirectURI),
-		spotifyauth.WithScopes(
-			spotifyauth.ScopeUserReadPrivate,
-			spotifyauth.ScopeUserModifyPlaybackState,
-			spotifyauth.ScopeUserReadPlaybackState,
-			spotifyauth.ScopeUserLibraryRead,
-			spotifyauth.ScopePlaylistReadCollaborative,
-			spotifyauth.ScopePlaylistReadPrivate,
-			spotifyauth.ScopeUserReadCurrentlyPlaying,
-		))
-	tokenCh       = make(chan *oauth2.Token)
+	scopes = []string{
+		spotifyauth.ScopeUserReadPrivate,
+		spotifyauth.ScopeUserModifyPlaybackState,
+		spotifyauth.ScopeUserReadPlaybackState,
+		spotifyauth.ScopeUserLibraryRead,
+		spotifyauth.ScopePlaylistReadCollaborative,
+		spotifyauth.ScopePlaylistReadPrivate,
+		spotifyauth.ScopeUserReadCurrentlyPlaying,
+		// Needed for the resume points of podcast episodes.
+		scopeUserReadPlaybackPosition,
+	}
+	auth          = newOAuthConfig()
 	tokenFilePath = ".config/sptui/spotify_token.json"
 )
 
+// apiURL returns the Web API base URL. Set SPOTIFY_API_URL to use a local
+// stand-in such as `sptui mock-server`.
+func apiURL() string {
+	if u := os.Getenv("SPOTIFY_API_URL"); u != "" {
+		return strings.TrimSuffix(u, "/") + "/"
+	}
+	return defaultAPIURL
+}
+
+// accountsURL returns the Accounts service base URL, overridable with
+// SPOTIFY_ACCOUNTS_URL.
+func accountsURL() string {
+	if u := os.Getenv("SPOTIFY_ACCOUNTS_URL"); u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return defaultAccountsURL
+}
+
+func newOAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     os.Getenv("SPOTIFY_ID"),
+		ClientSecret: os.Getenv("SPOTIFY_SECRET"),
+		RedirectURL:  LoginConfig{}.redirectURL(),
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  accountsURL() + "/authorize",
+			TokenURL: accountsURL() + "/api/token",
+		},
+	}
+}
+
 func generateCodeVerifier() (string, error) {
 	randomBytes := make([]byte, 32)
 	_, err := rand.Read(randomBytes)
@@ -65,155 +106,375 @@ func generateState() string {
 	return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(randomBytes)
 }
 
-type TokenExpiredError struct {
-	Message string
+// ErrTokenRevoked means that the refresh token is no longer valid and the
+// user has to log in again.
+var ErrTokenRevoked = errors.New("spotify session expired, please log in again")
+
+// tokenRefreshMargin is how long before expiry a token is refreshed, so that
+// a request never goes out with a token about to lapse.
+const tokenRefreshMargin = 5 * time.Minute
+
+type AuthMsg struct {
+	client Backend
 }
 
-func (e TokenExpiredError) Error() string {
-	return e.Message
+// LoginMsg asks the user to authorize sptui at URL. Either Wait blocks until
+// they have, or, in headless mode, Paste takes the URL they were redirected
+// to. Both end in an AuthMsg or an AuthErrMsg.
+type LoginMsg struct {
+	URL   string
+	Wait  tea.Cmd
+	Paste func(pasted string) tea.Cmd
 }
 
-func NewTokenExpiredError() error {
-	return TokenExpiredError{Message: "Token is expired"}
+// AuthErrMsg reports that sptui could not log in.
+type AuthErrMsg struct {
+	Err error
 }
 
-func isTokenExpiredError(err error) bool {
-	_, ok := err.(TokenExpiredError)
-	return ok
+// tokenSource hands out the stored token of a profile, refreshing it shortly
+// before it expires and saving the refreshed token.
+type tokenSource struct {
+	mu      sync.Mutex
+	store   TokenStore
+	profile Profile
+	token   *oauth2.Token
 }
 
-type AuthMsg struct {
-	client *spotify.Client
+func newTokenSource(store TokenStore, profile Profile, token *oauth2.Token) *tokenSource {
+	return &tokenSource{store: store, profile: profile, token: token}
 }
 
-func saveOAuthToken(token *oauth2.Token) error {
-	homeDir, _ := os.UserHomeDir()
-	dir := filepath.Dir(filepath.Join(homeDir, tokenFilePath))
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		os.MkdirAll(dir, 0700)
-	}
+func (s *tokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	jsonToken, err := json.Marshal(token)
+	if time.Until(s.token.Expiry) > tokenRefreshMargin {
+		return s.token, nil
+	}
+	token, err := refreshToken(context.Background(), s.profile.ClientID, s.token)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return os.WriteFile(filepath.Join(homeDir, tokenFilePath), jsonToken, 0600)
+	s.token = token
+	// The token works whether or not it was saved; at worst the next run
+	// refreshes it again.
+	if err := s.store.Save(s.profile.Name, token); err != nil {
+		log.Printf("saving refreshed token: %v", err)
+	}
+	return token, nil
 }
 
-func loadOAuthToken() (*oauth2.Token, error) {
-	homeDir, _ := os.UserHomeDir()
-	jsonToken, err := os.ReadFile(filepath.Join(homeDir, tokenFilePath))
+// refreshToken exchanges the refresh token of oldToken for a new token. It
+// returns ErrTokenRevoked if the Accounts service rejects the refresh token.
+func refreshToken(ctx context.Context, clientID string, oldToken *oauth2.Token) (*oauth2.Token, error) {
+	if oldToken.RefreshToken == "" {
+		return nil, ErrTokenRevoked
+	}
+	if clientID == "" {
+		return nil, errors.New("SPOTIFY_ID is not set")
+	}
+
+	// Every API call waits for the token, so a hung Accounts service must
+	// not hold them up for longer than a request may take.
+	ctx, cancel := request(ctx)
+	defer cancel()
+
+	form := url.Values{}
+	form.Add("grant_type", "refresh_token")
+	form.Add("refresh_token", oldToken.RefreshToken)
+	form.Add("client_id", clientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.Endpoint.TokenURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	var token oauth2.Token
-	if err := json.Unmarshal(jsonToken, &token); err != nil {
-		return nil, err
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("refreshing token: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if token.Expiry.Before(time.Now()) {
-		return &token, NewTokenExpiredError()
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("refreshing token: %s: %w", resp.Status, err)
+	}
+	if body.Error == "invalid_grant" {
+		return nil, ErrTokenRevoked
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return nil, fmt.Errorf("refreshing token: %s: %s", resp.Status, body.ErrorDescription)
 	}
 
-	return &token, nil
+	newToken := &oauth2.Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+	}
+	// The refresh token is only sent when it has been rotated.
+	if newToken.RefreshToken == "" {
+		newToken.RefreshToken = oldToken.RefreshToken
+	}
+	if body.ExpiresIn == 0 {
+		newToken.Expiry = time.Now().Add(time.Hour)
+	}
+	return newToken, nil
 }
 
-func refreshToken(oldToken *oauth2.Token) *oauth2.Token {
+// LoginConfig is the [login] section of the config file.
+type LoginConfig struct {
+	// Headless skips the browser and the local callback server. The user
+	// pastes the URL they were redirected to, or the code in it, instead.
+	Headless bool `toml:"headless"`
+	// RedirectHost and RedirectPort make up the redirect URI registered for
+	// the app, http://<host>:<port>/callback.
+	RedirectHost string `toml:"redirect_host"`
+	RedirectPort int    `toml:"redirect_port"`
+}
 
-	form := url.Values{}
-	form.Add("grant_type", "refresh_token")
-	form.Add("refresh_token", oldToken.RefreshToken)
-	client_id := os.Getenv("SPOTIFY_ID")
-	if client_id == "" {
-		log.Fatalf("SPOTIFY_ID is not set")
+func (c LoginConfig) redirectURL() string {
+	host, port := c.RedirectHost, c.RedirectPort
+	if host == "" {
+		host = defaultRedirectHost
 	}
-	form.Add("client_id", client_id)
+	if port == 0 {
+		port = defaultRedirectPort
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/callback"
+}
 
-	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token", strings.NewReader(form.Encode()))
+// pendingLogin is an authorization request waiting for its code.
+type pendingLogin struct {
+	config   *oauth2.Config
+	store    TokenStore
+	profile  Profile
+	state    string
+	verifier string
+}
+
+func newPendingLogin(cfg LoginConfig, store TokenStore, profile Profile) (*pendingLogin, error) {
+	if profile.ClientID == "" {
+		return nil, errors.New("SPOTIFY_ID is not set")
+	}
+	verifier, err := generateCodeVerifier()
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, err
 	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	config := *auth
+	config.ClientID = profile.ClientID
+	config.RedirectURL = cfg.redirectURL()
+	return &pendingLogin{config: &config, store: store, profile: profile, state: generateState(), verifier: verifier}, nil
+}
+
+func (p *pendingLogin) authURL() string {
+	return p.config.AuthCodeURL(p.state,
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		oauth2.SetAuthURLParam("code_challenge", generateCodeChallenge(p.verifier)),
+	)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (p *pendingLogin) exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	tok, err := p.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", p.verifier))
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, fmt.Errorf("getting token: %w", err)
 	}
-	defer resp.Body.Close()
+	return tok, nil
+}
+
+// authMsg saves tok and returns an AuthMsg for it.
+func (p *pendingLogin) authMsg(tok *oauth2.Token) tea.Msg {
+	if err := p.store.Save(p.profile.Name, tok); err != nil {
+		return AuthErrMsg{Err: fmt.Errorf("saving token: %w", err)}
+	}
+	return newAuthMsg(newTokenSource(p.store, p.profile, tok))
+}
+
+// codeFrom extracts the authorization code from a pasted redirect URL, or
+// takes the input as the code itself.
+func (p *pendingLogin) codeFrom(pasted string) (string, error) {
+	pasted = strings.TrimSpace(pasted)
+	if !strings.Contains(pasted, "?") {
+		if pasted == "" {
+			return "", errors.New("no code pasted")
+		}
+		return pasted, nil
+	}
+	u, err := url.Parse(pasted)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if e := q.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization failed: %s", e)
+	}
+	if st := q.Get("state"); st != p.state {
+		return "", fmt.Errorf("state mismatch: %s != %s", st, p.state)
+	}
+	if q.Get("code") == "" {
+		return "", errors.New("the URL has no code")
+	}
+	return q.Get("code"), nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// login starts the browser login flow. The returned LoginMsg waits for the
+// callback.
+func login(cfg LoginConfig, store TokenStore, profile Profile) (LoginMsg, error) {
+	p, err := newPendingLogin(cfg, store, profile)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return LoginMsg{}, err
 	}
 
-	var newToken oauth2.Token
-	if err := json.Unmarshal(body, &newToken); err != nil {
-		log.Fatal(err)
-		return nil
+	type result struct {
+		token *oauth2.Token
+		err   error
 	}
-	if newToken.Expiry.IsZero() {
-		newToken.Expiry = time.Now().Add(time.Duration(3600) * time.Second)
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", completeAuth(p, func(tok *oauth2.Token, err error) {
+		select {
+		case results <- result{tok, err}:
+		default:
+		}
+	}))
+	redirect, err := url.Parse(p.config.RedirectURL)
+	if err != nil {
+		return LoginMsg{}, err
+	}
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return LoginMsg{}, fmt.Errorf("listening for the login callback: %w", err)
 	}
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
 
-	return &newToken
+	authURL := p.authURL()
+	browser.OpenURL(authURL)
+
+	wait := func() tea.Msg {
+		r := <-results
+		server.Shutdown(context.Background())
+		if r.err != nil {
+			return AuthErrMsg{Err: r.err}
+		}
+		return p.authMsg(r.token)
+	}
+	return LoginMsg{URL: authURL, Wait: wait}, nil
 }
 
-func login() {
-	verifier, _ := generateCodeVerifier()
-	challenge := generateCodeChallenge(verifier)
-	state := generateState()
+// loginHeadless starts a login that completes with the redirect URL pasted
+// into the TUI.
+func loginHeadless(cfg LoginConfig, store TokenStore, profile Profile) (LoginMsg, error) {
+	p, err := newPendingLogin(cfg, store, profile)
+	if err != nil {
+		return LoginMsg{}, err
+	}
+	paste := func(pasted string) tea.Cmd {
+		return func() tea.Msg {
+			code, err := p.codeFrom(pasted)
+			if err != nil {
+				return AuthErrMsg{Err: err}
+			}
+			tok, err := p.exchange(context.Background(), code)
+			if err != nil {
+				return AuthErrMsg{Err: err}
+			}
+			return p.authMsg(tok)
+		}
+	}
+	return LoginMsg{URL: p.authURL(), Paste: paste}, nil
+}
 
-	http.HandleFunc("/callback", completeAuth(state, verifier))
-	go http.ListenAndServe(":21112", nil)
+func newAuthMsg(ts oauth2.TokenSource) AuthMsg {
+	return AuthMsg{newBackend(ts)}
+}
 
-	url := auth.AuthURL(state,
-		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
-		oauth2.SetAuthURLParam("code_challenge", challenge),
-	)
-	fmt.Println("Please log in to Spotify by visiting the following page in your browser:", url)
+func newBackend(ts oauth2.TokenSource) Backend {
+	// oauth2.NewClient would cache the token until it expires, which leaves
+	// no room to refresh it early.
+	client := &http.Client{Transport: newAPITransport(&oauth2.Transport{Source: ts})}
+	return NewClientBackend(client, apiURL())
+}
 
-	browser.OpenURL(url)
+// savedLogin returns a token source for the token of profile in store,
+// refreshing it if needed. A plaintext token left from before store was
+// chosen is moved into it. The error wraps fs.ErrNotExist when there is no
+// token and is ErrTokenRevoked when it can no longer be refreshed.
+func savedLogin(store TokenStore, profile Profile) (*tokenSource, error) {
+	if err := migrateToken(store, profile.Name); err != nil {
+		return nil, err
+	}
+	token, err := store.Load(profile.Name)
+	if err != nil {
+		return nil, fmt.Errorf("loading token: %w", err)
+	}
+	ts := newTokenSource(store, profile, token)
+	// Other errors are left for the first request to report.
+	if _, err := ts.Token(); errors.Is(err, ErrTokenRevoked) {
+		return nil, err
+	}
+	return ts, nil
 }
 
-func loginCmd() tea.Cmd {
+// NewSavedBackend returns a Backend for the saved login of profile, for
+// use without the TUI.
+func NewSavedBackend(store TokenStore, profile Profile) (Backend, error) {
+	ts, err := savedLogin(store, profile)
+	if err != nil {
+		return nil, err
+	}
+	return newBackend(ts), nil
+}
+
+// loginCmd authorizes profile with its saved login and falls back to
+// logging in when there is none or it was revoked.
+func loginCmd(cfg LoginConfig, store TokenStore, profile Profile) tea.Cmd {
 	return func() tea.Msg {
-		token, err := loadOAuthToken()
+		ts, err := savedLogin(store, profile)
+		if err == nil {
+			return newAuthMsg(ts)
+		}
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, ErrTokenRevoked) {
+			return AuthErrMsg{Err: err}
+		}
 
+		var msg LoginMsg
+		if cfg.Headless {
+			msg, err = loginHeadless(cfg, store, profile)
+		} else {
+			msg, err = login(cfg, store, profile)
+		}
 		if err != nil {
-			if os.IsNotExist(err) {
-				login()
-				token = <-tokenCh
-			} else if isTokenExpiredError(err) {
-				token = refreshToken(token)
-			} else {
-				log.Fatal(err)
-			}
-			saveOAuthToken(token)
+			return AuthErrMsg{Err: err}
 		}
-
-		client := spotify.New(auth.Client(context.Background(), token))
-		return AuthMsg{client}
+		return msg
 	}
 }
 
-func completeAuth(state string, verifer string) http.HandlerFunc {
+func completeAuth(p *pendingLogin, done func(*oauth2.Token, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tok, err := auth.Token(r.Context(), state, r,
-			oauth2.SetAuthURLParam("code_verifier", verifer))
+		if st := r.FormValue("state"); st != p.state {
+			http.NotFound(w, r)
+			done(nil, fmt.Errorf("state mismatch: %s != %s", st, p.state))
+			return
+		}
+		tok, err := p.exchange(r.Context(), r.FormValue("code"))
 		if err != nil {
 			http.Error(w, "Couldn't get token", http.StatusForbidden)
-			log.Fatal(err)
-		}
-		if st := r.FormValue("state"); st != state {
-			http.NotFound(w, r)
-			log.Fatalf("State mismatch: %s != %s\n", st, state)
+			done(nil, err)
+			return
 		}
-		tokenCh <- tok
+		fmt.Fprintln(w, "Login complete. You can close this page and return to sptui.")
+		done(tok, nil)
 	}
 }
@@ -0,0 +1,109 @@
+package sptui
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCodeFrom(t *testing.T) {
+	p := &pendingLogin{state: "st4te"}
+	tests := []struct {
+		name   string
+		pasted string
+		want   string
+		// err is part of the expected error, or empty if there is none.
+		err string
+	}{
+		{name: "redirect URL", pasted: "http://localhost:21112/callback?code=c0de&state=st4te", want: "c0de"},
+		{name: "surrounding space", pasted: "  http://localhost:21112/callback?state=st4te&code=c0de\n", want: "c0de"},
+		{name: "bare code", pasted: "c0de", want: "c0de"},
+		{name: "nothing", pasted: " ", err: "no code pasted"},
+		{name: "denied", pasted: "http://localhost:21112/callback?error=access_denied&state=st4te", err: "access_denied"},
+		{name: "other login", pasted: "http://localhost:21112/callback?code=c0de&state=other", err: "state mismatch"},
+		{name: "no code", pasted: "http://localhost:21112/callback?state=st4te", err: "no code"},
+		{name: "malformed", pasted: "http://[::1/callback?code=c0de", err: "missing ']'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.codeFrom(tt.pasted)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %q, %v; want error %s", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// failingStore is a TokenStore that cannot save.
+type failingStore struct{ fileStore }
+
+func (failingStore) Save(string, *oauth2.Token) error {
+	return errors.New("read-only file system")
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		store  TokenStore
+		// want is the access token handed out, or empty for an error.
+		want string
+		err  error
+	}{
+		{name: "refreshed", status: http.StatusOK, body: `{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`, want: "fresh"},
+		{name: "not saved", status: http.StatusOK, body: `{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`, store: failingStore{}, want: "fresh"},
+		{name: "revoked", status: http.StatusBadRequest, body: `{"error":"invalid_grant","error_description":"Refresh token revoked"}`, err: ErrTokenRevoked},
+		{name: "bad client", status: http.StatusBadRequest, body: `{"error":"invalid_client","error_description":"Invalid client"}`},
+		{name: "not JSON", status: http.StatusBadGateway, body: `<html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "r3fresh" {
+					t.Errorf("got form %v", r.Form)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			defer func(url string) { auth.Endpoint.TokenURL = url }(auth.Endpoint.TokenURL)
+			auth.Endpoint.TokenURL = srv.URL
+
+			store := tt.store
+			if store == nil {
+				store = fileStore{}
+			}
+			expired := &oauth2.Token{AccessToken: "stale", RefreshToken: "r3fresh", Expiry: time.Now()}
+			token, err := newTokenSource(store, Profile{Name: DefaultProfile, ClientID: "id"}, expired).Token()
+			if tt.want == "" {
+				if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, %v; want error %v", token, err, tt.err)
+				}
+				return
+			}
+			if err != nil || token.AccessToken != tt.want || token.RefreshToken != "r3fresh" {
+				t.Fatalf("got %+v, %v; want %s", token, err, tt.want)
+			}
+			if tt.store != nil {
+				return
+			}
+			if saved, err := store.Load(DefaultProfile); err != nil || saved.AccessToken != tt.want {
+				t.Errorf("saved %+v, %v", saved, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package sptui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Backend is the subset of the Spotify Web API that the TUI depends on.
+// Paged calls take an explicit offset so that implementations other than
+// the real client can honour paging.
+type Backend interface {
+	// Library
+	CurrentUsersAlbums(ctx context.Context, offset int) (*spotify.SavedAlbumPage, error)
+	CurrentUsersPlaylists(ctx context.Context, offset int) (*spotify.SimplePlaylistPage, error)
+	CurrentUsersShows(ctx context.Context, offset int) (*spotify.SavedShowPage, error)
+
+	// Detail
+	// GetAlbum returns an album with the first page of its tracks.
+	GetAlbum(ctx context.Context, id spotify.ID) (*spotify.FullAlbum, error)
+	GetAlbumTracks(ctx context.Context, id spotify.ID, offset int) (*spotify.SimpleTrackPage, error)
+	// GetPlaylist returns a playlist with the first page of its tracks.
+	GetPlaylist(ctx context.Context, id spotify.ID) (*spotify.FullPlaylist, error)
+	GetPlaylistTracks(ctx context.Context, id spotify.ID, offset int) (*spotify.PlaylistTrackPage, error)
+	// GetShow returns a show with the first page of its episodes.
+	GetShow(ctx context.Context, id spotify.ID) (*spotify.FullShow, error)
+	GetShowEpisodes(ctx context.Context, id spotify.ID, offset int) (*spotify.SimpleEpisodePage, error)
+	// GetEpisode returns an episode with its show and the user's resume point.
+	GetEpisode(ctx context.Context, id spotify.ID) (*spotify.EpisodePage, error)
+
+	// Search looks up tracks, albums, artists, playlists and shows at once.
+	Search(ctx context.Context, query string, offset int) (*spotify.SearchResult, error)
+
+	// Player
+	// The player calls include episodes, squeezed into a FullTrack whose
+	// Type is "episode" and which has no artists or album.
+	PlayerCurrentlyPlaying(ctx context.Context) (*spotify.CurrentlyPlaying, error)
+	// PlayerState returns nil when no device is active.
+	PlayerState(ctx context.Context) (*spotify.PlayerState, error)
+	PlayerDevices(ctx context.Context) ([]spotify.PlayerDevice, error)
+	// TransferPlayback moves playback to another device, starting it if
+	// play is set.
+	TransferPlayback(ctx context.Context, deviceID spotify.ID, play bool) error
+	PlayOpt(ctx context.Context, opt *spotify.PlayOptions) error
+	Pause(ctx context.Context) error
+	Next(ctx context.Context) error
+	Previous(ctx context.Context) error
+	Seek(ctx context.Context, positionMs int) error
+	Volume(ctx context.Context, percent int) error
+	Shuffle(ctx context.Context, shuffle bool) error
+	// Repeat sets the repeat mode to "off", "context" or "track".
+	Repeat(ctx context.Context, state string) error
+
+	// Queue
+	GetQueue(ctx context.Context) (*spotify.Queue, error)
+	// QueueItem adds a track or episode URI to the end of the queue.
+	QueueItem(ctx context.Context, uri spotify.URI) error
+}
+
+// Without this the Web API reports no item while an episode plays.
+var playerItemTypes = spotify.AdditionalTypes(spotify.EpisodeAdditionalType, spotify.TrackAdditionalType)
+
+// clientBackend adapts *spotify.Client to Backend. The HTTP client and base
+// URL are kept for endpoints the library does not cover.
+type clientBackend struct {
+	client  *spotify.Client
+	http    *http.Client
+	baseURL string
+}
+
+// NewClientBackend returns a Backend for the Web API at baseURL, sending
+// requests with httpClient, which is expected to add authorization.
+func NewClientBackend(httpClient *http.Client, baseURL string) Backend {
+	return clientBackend{
+		client:  spotify.New(httpClient, spotify.WithBaseURL(baseURL)),
+		http:    httpClient,
+		baseURL: baseURL,
+	}
+}
+
+func (b clientBackend) CurrentUsersAlbums(ctx context.Context, offset int) (*spotify.SavedAlbumPage, error) {
+	return b.client.CurrentUsersAlbums(ctx, spotify.Offset(offset))
+}
+
+func (b clientBackend) CurrentUsersPlaylists(ctx context.Context, offset int) (*spotify.SimplePlaylistPage, error) {
+	return b.client.CurrentUsersPlaylists(ctx, spotify.Offset(offset))
+}
+
+func (b clientBackend) CurrentUsersShows(ctx context.Context, offset int) (*spotify.SavedShowPage, error) {
+	return b.client.CurrentUsersShows(ctx, spotify.Offset(offset))
+}
+
+func (b clientBackend) GetAlbum(ctx context.Context, id spotify.ID) (*spotify.FullAlbum, error) {
+	return b.client.GetAlbum(ctx, id)
+}
+
+func (b clientBackend) GetAlbumTracks(ctx context.Context, id spotify.ID, offset int) (*spotify.SimpleTrackPage, error) {
+	return b.client.GetAlbumTracks(ctx, id, spotify.Offset(offset))
+}
+
+func (b clientBackend) GetPlaylist(ctx context.Context, id spotify.ID) (*spotify.FullPlaylist, error) {
+	return b.client.GetPlaylist(ctx, id)
+}
+
+// GetPlaylistTracks uses the deprecated tracks call because it returns the
+// same page type as GetPlaylist.
+func (b clientBackend) GetPlaylistTracks(ctx context.Context, id spotify.ID, offset int) (*spotify.PlaylistTrackPage, error) {
+	return b.client.GetPlaylistTracks(ctx, id, spotify.Offset(offset))
+}
+
+func (b clientBackend) GetShow(ctx context.Context, id spotify.ID) (*spotify.FullShow, error) {
+	return b.client.GetShow(ctx, id)
+}
+
+func (b clientBackend) GetShowEpisodes(ctx context.Context, id spotify.ID, offset int) (*spotify.SimpleEpisodePage, error) {
+	return b.client.GetShowEpisodes(ctx, id.String(), spotify.Offset(offset))
+}
+
+func (b clientBackend) GetEpisode(ctx context.Context, id spotify.ID) (*spotify.EpisodePage, error) {
+	return b.client.GetEpisode(ctx, id.String())
+}
+
+func (b clientBackend) Search(ctx context.Context, query string, offset int) (*spotify.SearchResult, error) {
+	return b.client.Search(ctx, query, searchTypes, spotify.Offset(offset), spotify.Limit(searchLimit))
+}
+
+func (b clientBackend) PlayerCurrentlyPlaying(ctx context.Context) (*spotify.CurrentlyPlaying, error) {
+	return b.client.PlayerCurrentlyPlaying(ctx, playerItemTypes)
+}
+
+func (b clientBackend) PlayerState(ctx context.Context) (*spotify.PlayerState, error) {
+	state, err := b.client.PlayerState(ctx, playerItemTypes)
+	if err != nil {
+		return nil, err
+	}
+	// The library decodes 204 No Content into an empty state.
+	if state.Device.ID == "" {
+		return nil, nil
+	}
+	return state, nil
+}
+
+func (b clientBackend) PlayerDevices(ctx context.Context) ([]spotify.PlayerDevice, error) {
+	return b.client.PlayerDevices(ctx)
+}
+
+func (b clientBackend) TransferPlayback(ctx context.Context, deviceID spotify.ID, play bool) error {
+	return b.client.TransferPlayback(ctx, deviceID, play)
+}
+
+func (b clientBackend) PlayOpt(ctx context.Context, opt *spotify.PlayOptions) error {
+	return b.client.PlayOpt(ctx, opt)
+}
+
+func (b clientBackend) Pause(ctx context.Context) error {
+	return b.client.Pause(ctx)
+}
+
+func (b clientBackend) Next(ctx context.Context) error {
+	return b.client.Next(ctx)
+}
+
+func (b clientBackend) Previous(ctx context.Context) error {
+	return b.client.Previous(ctx)
+}
+
+func (b clientBackend) Seek(ctx context.Context, positionMs int) error {
+	return b.client.Seek(ctx, positionMs)
+}
+
+func (b clientBackend) Volume(ctx context.Context, percent int) error {
+	return b.client.Volume(ctx, percent)
+}
+
+func (b clientBackend) Shuffle(ctx context.Context, shuffle bool) error {
+	return b.client.Shuffle(ctx, shuffle)
+}
+
+func (b clientBackend) Repeat(ctx context.Context, state string) error {
+	return b.client.Repeat(ctx, state)
+}
+
+func (b clientBackend) GetQueue(ctx context.Context) (*spotify.Queue, error) {
+	return b.client.GetQueue(ctx)
+}
+
+// QueueItem calls the endpoint directly because spotify.Client.QueueSong
+// only accepts track IDs.
+func (b clientBackend) QueueItem(ctx context.Context, uri spotify.URI) error {
+	v := url.Values{}
+	v.Set("uri", string(uri))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		b.baseURL+"me/player/queue?"+v.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return decodeAPIError(resp)
+	}
+	return nil
+}
+
+func decodeAPIError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var e struct {
+		E spotify.Error `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.E.Message == "" {
+		return spotify.Error{
+			Status:  resp.StatusCode,
+			Message: fmt.Sprintf("spotify: unexpected HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		}
+	}
+	return e.E
+}
@@ -1,48 +1,56 @@
 package sptui
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/zmb3/spotify/v2"
+	"github.com/mattn/go-runewidth"
 )
 
 const (
-	padding  = 2
-	maxWidth = 42
+	padding     = 2
+	minBarWidth = 10
 )
 
-var trackTitleStyle = lipgloss.NewStyle().
-	Foreground(lipgloss.Color("212")).Render
-
 type tickMsg struct {
 	id   string
 	time time.Time
 }
 
 type BarModel struct {
-	IsPlaying  bool
-	percent    float64
-	progress   progress.Model
-	deltaDur   float64
-	tickID     string
+	IsPlaying bool
+	percent   float64
+	progress  progress.Model
+	deltaDur  float64
+	tickID    string
+	// stopped is set when the item has played to its end and tickID no
+	// longer ticks.
+	stopped    bool
 	trackTitle string
 	titleAnim  AnimTextModel
 	animate    bool
+	styles     Styles
+	indent     int
+	width      int
+
+	shuffle bool
+	repeat  string
+	volume  int
 }
 
-func (m BarModel) UpdateBar(msg tea.Msg, client *spotify.Client) (BarModel, tea.Cmd) {
+func (m BarModel) UpdateBar(msg tea.Msg, client Backend) (BarModel, tea.Cmd) {
 	switch msg := msg.(type) {
 
-	case tea.WindowSizeMsg:
-		m.progress.Width = msg.Width - padding*2 - 4
-		if m.progress.Width > maxWidth {
-			m.progress.Width = maxWidth
+	case animTextTickMsg:
+		// Keep the animation ticking while the title fits so that it can
+		// start scrolling after a resize.
+		if !m.animate && m.tickID == msg.id {
+			return m, AnimTextTickCmd(m.tickID, 2000*time.Millisecond)
 		}
-		return m, nil
 
 	case tickMsg:
 		if m.tickID != msg.id {
@@ -52,7 +60,8 @@ func (m BarModel) UpdateBar(msg tea.Msg, client *spotify.Client) (BarModel, tea.
 			m.percent += m.deltaDur
 			if m.percent > 1.0 {
 				m.percent = 1.0
-				return m, GetCurrentlyPlayingTrackCmd(client)
+				m.stopped = true
+				return m, GetPlayerStateCmd(client)
 			}
 		}
 		return m, tickCmd(m.tickID)
@@ -68,32 +77,63 @@ func (m BarModel) UpdateBar(msg tea.Msg, client *spotify.Client) (BarModel, tea.
 }
 
 func (m BarModel) ViewBar() string {
-	pad := strings.Repeat(" ", padding)
+	pad := strings.Repeat(" ", m.indent)
 	view := pad + "🎧 "
 
 	if m.animate {
-		view += trackTitleStyle(m.titleAnim.ViewAnimText()) + "\n"
+		view += m.styles.trackTitle.Render(m.titleAnim.ViewAnimText()) + "\n"
 	} else {
-		view += trackTitleStyle(m.trackTitle) + "\n"
+		view += m.styles.trackTitle.Render(m.trackTitle) + "\n"
+	}
+	// Show the player state after the bar if there is room for both.
+	status := m.status()
+	if w := m.width - lipgloss.Width(status) - 2; status != "" && w >= minBarWidth {
+		m.progress.Width = w
+		return view + pad + m.progress.ViewAs(m.percent) + "  " + m.styles.status.Render(status)
 	}
 	view += pad + m.progress.ViewAs(m.percent)
 	return view
 }
 
+func (m BarModel) status() string {
+	var parts []string
+	if m.shuffle {
+		parts = append(parts, "shuffle")
+	}
+	switch m.repeat {
+	case "context":
+		parts = append(parts, "repeat")
+	case "track":
+		parts = append(parts, "repeat one")
+	}
+	if m.volume >= 0 {
+		parts = append(parts, fmt.Sprintf("vol %d%%", m.volume))
+	}
+	return strings.Join(parts, " ")
+}
+
 type BarConfig struct {
 	TickID     string
 	Percent    float64
 	IsPlaying  bool
 	DeltaDur   float64
 	TrackTitle string
+	Styles     Styles
+	// Indent and Width place the bar; zero means the default layout.
+	Indent int
+	Width  int
+
+	Shuffle bool
+	// Repeat is "off", "context" or "track".
+	Repeat string
+	// Volume is a percentage, or -1 if unknown.
+	Volume int
 }
 
 func NewBarModel(conf BarConfig) BarModel {
-	prog := progress.New(
-		progress.WithWidth(44),
+	prog := progress.New(append([]progress.Option{
 		progress.WithoutPercentage(),
-		progress.WithDefaultScaledGradient(),
-	)
+	}, conf.Styles.progress...)...)
 	m := BarModel{
 		progress:   prog,
 		percent:    conf.Percent,
@@ -101,15 +141,57 @@ func NewBarModel(conf BarConfig) BarModel {
 		deltaDur:   conf.DeltaDur,
 		tickID:     conf.TickID,
 		trackTitle: conf.TrackTitle,
+		styles:     conf.Styles,
+		shuffle:    conf.Shuffle,
+		repeat:     conf.Repeat,
+		volume:     conf.Volume,
 	}
-	if len(conf.TrackTitle) > maxWidth {
-		m.titleAnim = NewAnimText(conf.TrackTitle, conf.TickID,
-			WithWidth(maxWidth-4))
-		m.animate = true
+	if conf.Width == 0 {
+		conf.Indent, conf.Width = padding, defaultLayout.barWidth()
 	}
+	m.Resize(conf.Indent, conf.Width)
 	return m
 }
 
+// sync updates the bar to a newer state of the same item without
+// restarting its ticks or title animation. It returns the tick to resume
+// with if the bar had stopped at the end of the item, such as when it is
+// repeated.
+func (m *BarModel) sync(conf BarConfig) tea.Cmd {
+	m.percent = conf.Percent
+	m.IsPlaying = conf.IsPlaying
+	m.deltaDur = conf.DeltaDur
+	m.shuffle = conf.Shuffle
+	m.repeat = conf.Repeat
+	m.volume = conf.Volume
+	if !m.stopped {
+		return nil
+	}
+	m.stopped = false
+	return tickCmd(m.tickID)
+}
+
+// Resize fits the bar and track title into width columns after indent.
+// Titles that do not fit scroll.
+func (m *BarModel) Resize(indent, width int) {
+	m.indent = indent
+	m.width = width
+	m.progress.Width = max(width, 1)
+
+	// Leave room for the headphones.
+	titleWidth := max(width-3, 1)
+	if runewidth.StringWidth(m.trackTitle) <= titleWidth {
+		m.animate = false
+		return
+	}
+	if m.animate {
+		m.titleAnim.width = titleWidth
+		return
+	}
+	m.titleAnim = NewAnimText(m.trackTitle, m.tickID, WithWidth(titleWidth))
+	m.animate = true
+}
+
 func tickCmd(id string) tea.Cmd {
 	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
 		return tickMsg{
@@ -120,5 +202,9 @@ func tickCmd(id string) tea.Cmd {
 }
 
 func (m BarModel) PositionMs() int {
+	// Without a duration there is no progress to tell it from.
+	if m.deltaDur == 0 {
+		return 0
+	}
 	return int(1000 / m.deltaDur * m.percent)
 }
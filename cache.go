@@ -0,0 +1,174 @@
+package sptui
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmb3/spotify/v2"
+)
+
+// cacheMaxAge is how long an album or playlist that is not opened again
+// stays in the cache.
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// Files in the cache directory.
+const (
+	albumsCache    = "albums.json"
+	playlistsCache = "playlists.json"
+	showsCache     = "shows.json"
+)
+
+func albumCache(id spotify.ID) string {
+	return filepath.Join("album", string(id)+".json")
+}
+
+func playlistCache(id spotify.ID) string {
+	return filepath.Join("playlist", string(id)+".json")
+}
+
+// libraryCache keeps the first page of each library tab and the albums and
+// playlists opened so far under ~/.cache/sptui, so that they show before
+// the Web API answers. A nil cache keeps nothing.
+type libraryCache struct {
+	dir string
+}
+
+// newLibraryCache returns the cache of the profile's account, or nil if
+// there is no cache directory.
+func newLibraryCache(profile string) *libraryCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	return &libraryCache{dir: filepath.Join(dir, "sptui", profile)}
+}
+
+// load decodes the cached file name into v and reports whether it was
+// there.
+func (c *libraryCache) load(name string, v any) bool {
+	if c == nil {
+		return false
+	}
+	path := filepath.Join(c.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	// Entries that are still used are not pruned.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return json.Unmarshal(data, v) == nil
+}
+
+// saveCmd writes v to the cached file name. v is encoded right away, as
+// the model may change it before the command runs. The cache is only an
+// optimization, so errors are ignored.
+func (c *libraryCache) saveCmd(name string, v any) tea.Cmd {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return func() tea.Msg {
+		path := filepath.Join(c.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil
+		}
+		// Write to a temporary file first so that a concurrent load never
+		// sees half a file.
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+		if err != nil {
+			return nil
+		}
+		_, err = tmp.Write(data)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+		return nil
+	}
+}
+
+// loadLibraryCmd sends the cached library pages as AlbumMsg, PlaylistMsg
+// and ShowMsg with Cached set, and drops albums and playlists that have not
+// been opened for cacheMaxAge.
+func (c *libraryCache) loadLibraryCmd() tea.Cmd {
+	if c == nil {
+		return nil
+	}
+	return tea.Batch(
+		func() tea.Msg {
+			var albums spotify.SavedAlbumPage
+			if !c.load(albumsCache, &albums) {
+				return nil
+			}
+			return AlbumMsg{Albums: &albums, Cached: true}
+		},
+		func() tea.Msg {
+			var playlists spotify.SimplePlaylistPage
+			if !c.load(playlistsCache, &playlists) {
+				return nil
+			}
+			return PlaylistMsg{Playlists: &playlists, Cached: true}
+		},
+		func() tea.Msg {
+			var shows spotify.SavedShowPage
+			if !c.load(showsCache, &shows) {
+				return nil
+			}
+			return ShowMsg{Shows: &shows, Cached: true}
+		},
+		c.pruneCmd,
+	)
+}
+
+func (c *libraryCache) pruneCmd() tea.Msg {
+	for _, dir := range []string{"album", "playlist"} {
+		entries, err := os.ReadDir(filepath.Join(c.dir, dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err == nil && time.Since(info.ModTime()) > cacheMaxAge {
+				os.Remove(filepath.Join(c.dir, dir, e.Name()))
+			}
+		}
+	}
+	return nil
+}
+
+// getAlbumCmd loads an album from the cache, or from the Web API if it is
+// not cached.
+func (c *libraryCache) getAlbumCmd(ctx context.Context, client Backend, id spotify.ID) tea.Cmd {
+	return func() tea.Msg {
+		var album spotify.FullAlbum
+		if c.load(albumCache(id), &album) {
+			return AlbumDetailMsg{Album: &album, Cached: true}
+		}
+		return GetAlbumCmd(ctx, client, id)()
+	}
+}
+
+// getPlaylistCmd loads a playlist from the cache, or from the Web API if it
+// is not cached.
+func (c *libraryCache) getPlaylistCmd(ctx context.Context, client Backend, id spotify.ID) tea.Cmd {
+	return func() tea.Msg {
+		var playlist spotify.FullPlaylist
+		if c.load(playlistCache(id), &playlist) {
+			return PlaylistDetailMsg{Playlist: &playlist, Cached: true}
+		}
+		return GetPlaylistCmd(ctx, client, id)()
+	}
+}
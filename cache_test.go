@@ -0,0 +1,110 @@
+package sptui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestLibraryCacheLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is what the cached file holds, or nil if there is none.
+		file []byte
+		want spotify.ID
+		ok   bool
+	}{
+		{name: "saved", file: []byte(`{"id":"fakealbum1","name":"Aurora"}`), want: "fakealbum1", ok: true},
+		{name: "missing"},
+		{name: "cut short", file: []byte(`{"id":"fakealbum1","na`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &libraryCache{dir: t.TempDir()}
+			path := filepath.Join(c.dir, albumCache("fakealbum1"))
+			if tt.file != nil {
+				os.MkdirAll(filepath.Dir(path), 0700)
+				if err := os.WriteFile(path, tt.file, 0600); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-time.Hour)
+				os.Chtimes(path, old, old)
+			}
+
+			var album spotify.FullAlbum
+			if ok := c.load(albumCache("fakealbum1"), &album); ok != tt.ok || album.ID != tt.want {
+				t.Fatalf("load = %v with %q; want %v with %q", ok, album.ID, tt.ok, tt.want)
+			}
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > time.Minute {
+				t.Error("loading did not mark the file as used")
+			}
+		})
+	}
+
+	var nilCache *libraryCache
+	if nilCache.load(albumsCache, &spotify.SavedAlbumPage{}) || nilCache.saveCmd(albumsCache, nil) != nil {
+		t.Error("a nil cache keeps something")
+	}
+}
+
+func TestLibraryCacheSave(t *testing.T) {
+	c := &libraryCache{dir: t.TempDir()}
+	album := &spotify.FullAlbum{SimpleAlbum: spotify.SimpleAlbum{ID: "fakealbum1", Name: "Aurora"}}
+	cmd := c.saveCmd(albumCache(album.ID), album)
+	// The album is saved as it was when the command was made.
+	album.Name = "Renamed"
+	cmd()
+
+	var got spotify.FullAlbum
+	if !c.load(albumCache("fakealbum1"), &got) || got.Name != "Aurora" {
+		t.Errorf("loaded %q, want Aurora", got.Name)
+	}
+	// Saving again replaces the file and leaves no temporary files behind.
+	c.saveCmd(albumCache(album.ID), album)()
+	entries, err := os.ReadDir(filepath.Join(c.dir, "album"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "fakealbum1.json" {
+		t.Errorf("the cache holds %v, want fakealbum1.json", entries)
+	}
+	if !c.load(albumCache("fakealbum1"), &got) || got.Name != "Renamed" {
+		t.Errorf("loaded %q, want Renamed", got.Name)
+	}
+}
+
+func TestLibraryCachePrune(t *testing.T) {
+	c := &libraryCache{dir: t.TempDir()}
+	tests := []struct {
+		name string
+		age  time.Duration
+		kept bool
+	}{
+		{name: albumCache("recent"), age: time.Hour, kept: true},
+		{name: albumCache("forgotten"), age: cacheMaxAge + time.Hour},
+		{name: playlistCache("recent"), age: cacheMaxAge - time.Hour, kept: true},
+		{name: playlistCache("forgotten"), age: cacheMaxAge + time.Hour},
+		// The library pages are replaced on every start instead.
+		{name: albumsCache, age: cacheMaxAge + time.Hour, kept: true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(c.dir, tt.name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-tt.age)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	c.pruneCmd()
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(c.dir, tt.name))
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s kept = %v, want %v", tt.name, kept, tt.kept)
+		}
+	}
+}
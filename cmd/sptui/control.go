@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/szktkfm/sptui"
+	"github.com/zmb3/spotify/v2"
+)
+
+// Exit codes of the control subcommands.
+const (
+	exitError = iota + 1
+	exitUsage
+	exitNotLoggedIn
+	exitNoDevice
+)
+
+// control is a subcommand that drives playback without the TUI.
+type control struct {
+	args  string
+	nargs int
+	// run returns the value to print, or nil if there is nothing to print.
+	run func(client sptui.Backend, args []string) (any, error)
+}
+
+var controls = map[string]control{
+	"play": {run: func(client sptui.Backend, _ []string) (any, error) {
+		return nil, runCmd(sptui.StartPlaybackCmd(client, nil))
+	}},
+	"pause": {run: func(client sptui.Backend, _ []string) (any, error) {
+		return nil, runCmd(sptui.PausePlaybackCmd(client))
+	}},
+	"next": {run: func(client sptui.Backend, _ []string) (any, error) {
+		return nil, runCmd(sptui.NextPlaybackCmd(client))
+	}},
+	"prev": {run: func(client sptui.Backend, _ []string) (any, error) {
+		return nil, runCmd(sptui.PreviousPlaybackCmd(client))
+	}},
+	"devices": {run: func(client sptui.Backend, _ []string) (any, error) {
+		msg := sptui.GetAvailableDevicesCmd(client)()
+		if err, ok := msg.(sptui.ErrMsg); ok {
+			return nil, err.Err
+		}
+		return devices(msg.(sptui.PlayerDevicesMsg).PlayerDevices), nil
+	}},
+	"transfer": {args: "<device>", nargs: 1, run: transfer},
+	"play-uri": {args: "<uri>", nargs: 1, run: playURI},
+}
+
+// isControl reports whether name is a control subcommand.
+func isControl(name string) bool {
+	_, ok := controls[name]
+	return ok || name == "status"
+}
+
+// controlFlags holds the flags shared by the control subcommands.
+type controlFlags struct {
+	*flag.FlagSet
+	json    bool
+	profile string
+}
+
+func newControlFlags(name, args string) *controlFlags {
+	f := &controlFlags{FlagSet: flag.NewFlagSet(name, flag.ExitOnError)}
+	f.BoolVar(&f.json, "json", false, "print the result and errors as JSON")
+	f.StringVar(&f.profile, "profile", sptui.DefaultProfile, "account to use")
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "usage: sptui %s [flags] %s\n", name, args)
+		f.PrintDefaults()
+	}
+	return f
+}
+
+// parse parses args and exits unless nargs arguments are left.
+func (f *controlFlags) parse(args []string, nargs int) {
+	f.Parse(args)
+	if f.NArg() != nargs {
+		f.Usage()
+		os.Exit(exitUsage)
+	}
+}
+
+// fail reports err and exits with code.
+func (f *controlFlags) fail(code int, err error) {
+	if f.json {
+		json.NewEncoder(os.Stdout).Encode(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	os.Exit(code)
+}
+
+// failRequest reports an error from the Web API and exits.
+func (f *controlFlags) failRequest(err error) {
+	var apiErr spotify.Error
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound && strings.Contains(apiErr.Message, "device") {
+		f.fail(exitNoDevice, err)
+	}
+	f.fail(exitError, err)
+}
+
+// client logs in with the saved token of the chosen profile.
+func (f *controlFlags) client() sptui.Backend {
+	cfg, err := sptui.LoadConfig()
+	if err != nil {
+		f.fail(exitError, fmt.Errorf("loading config: %w", err))
+	}
+	p, err := cfg.Profile(f.profile)
+	if err != nil {
+		f.fail(exitUsage, err)
+	}
+	client, err := sptui.NewSavedBackend(tokenStore(cfg), p)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, sptui.ErrTokenRevoked) {
+		f.fail(exitNotLoggedIn, fmt.Errorf("profile %s is not logged in, run sptui to log in", p.Name))
+	}
+	if err != nil {
+		f.fail(exitError, err)
+	}
+	return client
+}
+
+func runControl(name string, args []string) {
+	if name == "status" {
+		runStatus(args)
+		return
+	}
+
+	c := controls[name]
+	flags := newControlFlags(name, c.args)
+	flags.parse(args, c.nargs)
+
+	result, err := c.run(flags.client(), flags.Args())
+	if err != nil {
+		flags.failRequest(err)
+	}
+	if result == nil {
+		return
+	}
+	if flags.json {
+		json.NewEncoder(os.Stdout).Encode(result)
+		return
+	}
+	printDevices(result.(devices))
+}
+
+// runStatus prints what is playing, or with -follow, a line every time that
+// changes.
+func runStatus(args []string) {
+	flags := newControlFlags("status", "")
+	format := flags.String("format", "", "print a line using a Go template, such as '{{.Artist}} - {{.Title}} {{.Progress}}'")
+	follow := flags.Bool("follow", false, "keep running and print a line whenever the status changes")
+	interval := flags.Duration("interval", 5*time.Second, "how often -follow asks Spotify for the status")
+	flags.parse(args, 0)
+
+	if *follow && *format == "" {
+		*format = defaultFollowFormat
+	}
+	tmpl, err := template.New("status").Parse(*format)
+	if err != nil {
+		flags.fail(exitUsage, fmt.Errorf("parsing -format: %w", err))
+	}
+	client := flags.client()
+
+	if !*follow {
+		np, err := sptui.GetNowPlaying(context.Background(), client)
+		if err != nil {
+			flags.failRequest(err)
+		}
+		switch {
+		case flags.json:
+			json.NewEncoder(os.Stdout).Encode(np)
+		case *format != "":
+			fmt.Println(renderStatus(flags, tmpl, np))
+		default:
+			printNowPlaying(np)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var last *sptui.NowPlaying
+	err = sptui.WatchNowPlaying(ctx, client, *interval, func(np sptui.NowPlaying) {
+		// The advancing position alone does not count as a change.
+		key := np
+		key.PositionMs = 0
+		if last != nil && *last == key {
+			return
+		}
+		last = &key
+		if flags.json {
+			json.NewEncoder(os.Stdout).Encode(np)
+		} else {
+			fmt.Println(renderStatus(flags, tmpl, np))
+		}
+	}, func(err error) {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	})
+	if err != nil {
+		flags.failRequest(err)
+	}
+}
+
+// renderStatus executes the -format template, exiting on errors.
+func renderStatus(flags *controlFlags, tmpl *template.Template, np sptui.NowPlaying) string {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, np); err != nil {
+		flags.fail(exitUsage, fmt.Errorf("executing -format: %w", err))
+	}
+	return b.String()
+}
+
+const defaultFollowFormat = "{{if .Title}}{{.Artist}} - {{.Title}} {{.Progress}}{{end}}"
+
+// runCmd runs one of the TUI's commands and returns the error it reports.
+func runCmd(cmd tea.Cmd) error {
+	if msg, ok := cmd().(sptui.ErrMsg); ok {
+		return msg.Err
+	}
+	return nil
+}
+
+type devices []spotify.PlayerDevice
+
+// findDevice looks a device up by ID or, ignoring case, by name.
+func findDevice(client sptui.Backend, name string) (spotify.ID, error) {
+	msg := sptui.GetAvailableDevicesCmd(client)()
+	if err, ok := msg.(sptui.ErrMsg); ok {
+		return "", err.Err
+	}
+	for _, d := range msg.(sptui.PlayerDevicesMsg).PlayerDevices {
+		if string(d.ID) == name || strings.EqualFold(d.Name, name) {
+			return d.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no device %q, see sptui devices", name)
+}
+
+func transfer(client sptui.Backend, args []string) (any, error) {
+	id, err := findDevice(client, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return nil, runCmd(sptui.TransferPlaybackCmd(client, id, false))
+}
+
+func playURI(client sptui.Backend, args []string) (any, error) {
+	opt, err := sptui.PlayURIOptions(spotify.URI(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	return nil, runCmd(sptui.StartPlaybackCmd(client, opt))
+}
+
+func printNowPlaying(np sptui.NowPlaying) {
+	if np.Device == "" {
+		fmt.Println("No active device")
+		return
+	}
+	state := strings.ToUpper(np.State()[:1]) + np.State()[1:]
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\n", state, np.Title)
+	if np.Artist != "" {
+		fmt.Fprintf(w, "Artist\t%s\n", np.Artist)
+	}
+	if np.Album != "" {
+		fmt.Fprintf(w, "Album\t%s\n", np.Album)
+	}
+	if np.Progress() != "" {
+		fmt.Fprintf(w, "Progress\t%s\n", np.Progress())
+	}
+	fmt.Fprintf(w, "Device\t%s (%d%%)\n", np.Device, np.Volume)
+	fmt.Fprintf(w, "Shuffle\t%t\n", np.Shuffle)
+	fmt.Fprintf(w, "Repeat\t%s\n", np.Repeat)
+	w.Flush()
+}
+
+func printDevices(ds devices) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, d := range ds {
+		active := " "
+		if d.Active {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d%%\t%s\n", active, d.Name, d.Type, d.Volume, d.ID)
+	}
+	w.Flush()
+}
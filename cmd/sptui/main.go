@@ -1,17 +1,189 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/godbus/dbus/v5"
 	"github.com/szktkfm/sptui"
+	"golang.org/x/term"
 )
 
 func main() {
-	m := sptui.NewTabModel()
-	if _, err := tea.NewProgram(m, tea.WithoutSignalHandler()).Run(); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "mock-server" {
+		mockServer(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "profiles" {
+		profiles(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && isControl(os.Args[1]) {
+		runControl(os.Args[1], os.Args[2:])
+		return
+	}
+
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "usage: sptui [flags]")
+		fmt.Fprintln(out, "       sptui play | pause | next | prev | status | devices [-json] [-profile name]")
+		fmt.Fprintln(out, "       sptui transfer <device> | play-uri <uri> [-json] [-profile name]")
+		fmt.Fprintln(out, "       sptui profiles [list | remove <name>]")
+		fmt.Fprintln(out, "       sptui mock-server [-addr address]")
+		flag.PrintDefaults()
+	}
+	demo := flag.Bool("demo", false, "run against an in-memory fake library instead of Spotify")
+	theme := flag.String("theme", "", "color theme: "+strings.Join(sptui.ThemeNames(), ", "))
+	headless := flag.Bool("headless", false, "log in by pasting the redirect URL instead of using a local browser")
+	profile := flag.String("profile", sptui.DefaultProfile, "account to log in as")
+	flag.Parse()
+
+	cfg, err := sptui.LoadConfig()
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+	if *theme != "" {
+		if cfg.Theme, err = sptui.LookupTheme(*theme); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if *headless {
+		cfg.Login.Headless = true
+	}
+
+	p, err := cfg.Profile(*profile)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	opts := []sptui.TabModelOpt{sptui.WithConfig(cfg), sptui.WithProfile(p)}
+	if *demo {
+		opts = append(opts, sptui.WithBackend(sptui.NewFakeBackend()))
+	} else {
+		opts = append(opts, sptui.WithTokenStore(tokenStore(cfg)))
+	}
+
+	// Media keys work where there is a session bus, and sptui runs fine
+	// without one.
+	mpris := sptui.NewMPRIS()
+	bus, err := dbus.ConnectSessionBus()
+	if err == nil {
+		defer bus.Close()
+		opts = append(opts, sptui.WithPlayerObserver(mpris))
+	}
+	ctl := sptui.NewControlServer()
+	opts = append(opts, sptui.WithPlayerObserver(ctl))
+
+	// Warnings written while the TUI is on screen would garble it.
+	if path := os.Getenv("SPTUI_LOG"); path != "" {
+		if f, err := tea.LogToFile(path, ""); err == nil {
+			defer f.Close()
+		}
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	m := sptui.NewTabModel(opts...)
+	prog := tea.NewProgram(m, tea.WithoutSignalHandler())
+	if bus != nil {
+		if err := mpris.Start(bus, prog.Send); err != nil {
+			fmt.Fprintln(os.Stderr, "Media keys are off:", err)
+		}
+	}
+	// Only the first sptui serves the control socket.
+	if err := ctl.Start(sptui.ControlSocketPath(), prog.Send); err == nil {
+		defer ctl.Close()
+	}
+	if _, err := prog.Run(); err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
 	}
 }
+
+func mockServer(args []string) {
+	fs := flag.NewFlagSet("mock-server", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:21113", "listen address")
+	fs.Parse(args)
+
+	fmt.Printf("Serving a mock Spotify Web API on http://%s\n", *addr)
+	fmt.Printf("  export SPOTIFY_API_URL=http://%s/v1/\n", *addr)
+	fmt.Printf("  export SPOTIFY_ACCOUNTS_URL=http://%s\n", *addr)
+	if err := http.ListenAndServe(*addr, sptui.NewMockServer(sptui.NewFakeBackend())); err != nil {
+		fmt.Println("Error running mock server:", err)
+		os.Exit(1)
+	}
+}
+
+func profiles(args []string) {
+	usage := func() {
+		fmt.Println("usage: sptui profiles [list | remove <name>]")
+		os.Exit(2)
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		cfg, err := sptui.LoadConfig()
+		if err != nil {
+			fmt.Println("Error loading config:", err)
+			os.Exit(1)
+		}
+		list, err := sptui.ListProfiles(cfg, tokenStore(cfg))
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		for _, p := range list {
+			status := "not logged in"
+			if p.LoggedIn {
+				status = "logged in"
+			}
+			if !p.Configured {
+				status += ", client ID from SPOTIFY_ID"
+			}
+			fmt.Printf("%s\t%s\n", p.Name, status)
+		}
+		return
+	}
+
+	if args[0] != "remove" || len(args) != 2 {
+		usage()
+	}
+	cfg, err := sptui.LoadConfig()
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+	if err := sptui.RemoveProfile(tokenStore(cfg), args[1]); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed the login of profile %s\n", args[1])
+}
+
+// tokenStore opens the token store chosen in the config file. The passphrase
+// of the encrypted store is read from SPTUI_TOKEN_PASSPHRASE, or asked for.
+func tokenStore(cfg sptui.Config) sptui.TokenStore {
+	store, err := sptui.NewTokenStore(cfg.Token, func() (string, error) {
+		if p, ok := os.LookupEnv("SPTUI_TOKEN_PASSPHRASE"); ok {
+			return p, nil
+		}
+		fmt.Print("Token passphrase: ")
+		p, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		return string(p), err
+	})
+	if err != nil {
+		fmt.Println("Error opening the token store:", err)
+		os.Exit(1)
+	}
+	return store
+}
@@ -0,0 +1,168 @@
+package sptui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// column is a field of item shown in a track list.
+type column int
+
+const (
+	columnTitle column = iota
+	columnArtist
+	columnAlbum
+	columnDuration
+	columnAddedAt
+	columnResume
+)
+
+const columnGap = 2
+
+var (
+	playlistColumns = []column{columnTitle, columnArtist, columnAlbum, columnDuration, columnAddedAt}
+	albumColumns    = []column{columnTitle, columnArtist, columnDuration}
+	queueColumns    = []column{columnTitle, columnArtist, columnAlbum, columnDuration}
+	episodeColumns  = []column{columnTitle, columnResume, columnDuration, columnAddedAt}
+)
+
+// Columns with a weight share the width left over after every column gets
+// its minimum. The others stay at their minimum.
+var columnSpecs = map[column]struct{ min, weight int }{
+	columnTitle:    {12, 3},
+	columnArtist:   {8, 2},
+	columnAlbum:    {8, 2},
+	columnDuration: {7, 0},
+	columnAddedAt:  {10, 0},
+	columnResume:   {10, 0},
+}
+
+// collapseOrder lists the columns dropped, first to last, when the list is
+// too narrow for all of them. The title is never dropped.
+var collapseOrder = []column{columnAddedAt, columnAlbum, columnArtist, columnResume, columnDuration}
+
+// fitColumns drops columns until the rest fit into width and returns the
+// width of each remaining column.
+func fitColumns(cols []column, width int) ([]column, []int) {
+	cols = slices.Clone(cols)
+	for _, drop := range collapseOrder {
+		if minRowWidth(cols) <= width {
+			break
+		}
+		cols = slices.DeleteFunc(cols, func(c column) bool { return c == drop })
+	}
+
+	spare := max(width-minRowWidth(cols), 0)
+	totalWeight := 0
+	for _, c := range cols {
+		totalWeight += columnSpecs[c].weight
+	}
+
+	widths := make([]int, len(cols))
+	used := columnGap * (len(cols) - 1)
+	title := -1
+	for i, c := range cols {
+		if c == columnTitle {
+			title = i
+			continue
+		}
+		spec := columnSpecs[c]
+		widths[i] = spec.min + spare*spec.weight/totalWeight
+		used += widths[i]
+	}
+	// The title takes whatever is left, including rounding remainders.
+	if title >= 0 {
+		widths[title] = max(width-used, 1)
+	}
+	return cols, widths
+}
+
+func minRowWidth(cols []column) int {
+	w := columnGap * max(len(cols)-1, 0)
+	for _, c := range cols {
+		w += columnSpecs[c].min
+	}
+	return w
+}
+
+// span is a range of runes in an item's FilterValue.
+type span struct{ start, end int }
+
+// cells lays out the columns of i in width terminal cells. It also returns
+// the part of the filter value each column shows, which is empty for the
+// columns that filtering ignores.
+func (i item) cells(cols []column, width int) ([]string, []span) {
+	cols, widths := fitColumns(cols, width)
+	cells := make([]string, len(cols))
+	spans := make([]span, len(cols))
+	title := span{0, len([]rune(CleanString(i.title)))}
+	artist := span{title.end + 1, title.end + 1 + len([]rune(CleanString(i.artist)))}
+	album := span{artist.end + 1, artist.end + 1 + len([]rune(CleanString(i.album)))}
+	for n, c := range cols {
+		switch c {
+		case columnTitle:
+			name := i.title
+			if i.explicit {
+				name += " [E]"
+			}
+			cells[n], spans[n] = cell(name, widths[n], false), title
+		case columnArtist:
+			cells[n], spans[n] = cell(i.artist, widths[n], false), artist
+		case columnAlbum:
+			cells[n], spans[n] = cell(i.album, widths[n], false), album
+		case columnDuration:
+			cells[n] = cell(formatDuration(i.duration), widths[n], true)
+		case columnAddedAt:
+			var added string
+			if !i.addedAt.IsZero() {
+				added = i.addedAt.Format(time.DateOnly)
+			}
+			cells[n] = cell(added, widths[n], false)
+		case columnResume:
+			cells[n] = cell(i.resumeStatus(), widths[n], true)
+		}
+	}
+	return cells, spans
+}
+
+// resumeStatus marks a fully played episode, or says how much of a started
+// one is left.
+func (i item) resumeStatus() string {
+	switch {
+	case i.played:
+		return "✓ played"
+	case i.resume > 0:
+		return formatDuration(i.duration-i.resume) + " left"
+	default:
+		return ""
+	}
+}
+
+// cell truncates or pads s to exactly width cells.
+func cell(s string, width int, alignRight bool) string {
+	s = CleanString(s)
+	if runewidth.StringWidth(s) > width {
+		s = runewidth.Truncate(s, width, "…")
+	}
+	pad := strings.Repeat(" ", width-runewidth.StringWidth(s))
+	if alignRight {
+		return pad + s
+	}
+	return s + pad
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
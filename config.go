@@ -0,0 +1,152 @@
+package sptui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+var configFilePath = ".config/sptui/config.toml"
+
+// Config is read from ~/.config/sptui/config.toml. Every field is optional.
+//
+//	theme = "light"
+//
+//	[keys]
+//	toggle = "space"
+//	next = [">", "L"]
+//
+//	[login]
+//	headless = true
+//	redirect_port = 8888
+//
+//	[profiles.work]
+//	client_id = "..."
+//
+//	[token]
+//	store = "keyring"
+//
+//	[player]
+//	poll_interval = "10s"
+type Config struct {
+	ThemeName string                   `toml:"theme"`
+	Keys      map[string]keyList       `toml:"keys"`
+	Login     LoginConfig              `toml:"login"`
+	Profiles  map[string]ProfileConfig `toml:"profiles"`
+	Token     TokenConfig              `toml:"token"`
+	Player    PlayerConfig             `toml:"player"`
+
+	// Theme is the built-in theme called ThemeName.
+	Theme Theme `toml:"-"`
+	// KeyMap is the default keymap with Keys applied.
+	KeyMap KeyMap `toml:"-"`
+}
+
+// LoadConfig reads the config file, falling back to the defaults when it
+// does not exist.
+func LoadConfig() (Config, error) {
+	homeDir, _ := os.UserHomeDir()
+	return loadConfigFile(filepath.Join(homeDir, configFilePath))
+}
+
+func loadConfigFile(path string) (Config, error) {
+	var cfg Config
+	if _, err := toml.DecodeFile(path, &cfg); err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+
+	theme, err := LookupTheme(cfg.ThemeName)
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Theme = theme
+
+	keys, err := applyKeys(DefaultKeyMap(), cfg.Keys)
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.KeyMap = keys
+
+	if err := cfg.Token.validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := cfg.Player.validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name := range cfg.Profiles {
+		if _, err := cfg.Profile(name); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// listKeys are the keys that lists keep for themselves, with what they do.
+var listKeys = map[string]string{
+	"g":      "jumps to the first entry",
+	"home":   "jumps to the first entry",
+	"G":      "jumps to the last entry",
+	"end":    "jumps to the last entry",
+	"pgup":   "shows the previous page",
+	"pgdown": "shows the next page",
+}
+
+// applyKeys replaces the keys of every action named in remap and checks that
+// no key ends up bound to two actions, or to an action and a list.
+func applyKeys(keys KeyMap, remap map[string]keyList) (KeyMap, error) {
+	actions := keys.actions()
+	for name, ks := range remap {
+		b, ok := actions[name]
+		if !ok {
+			return keys, fmt.Errorf("keys: unknown action %q", name)
+		}
+		setKeys(b, ks...)
+	}
+
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	boundTo := map[string]string{}
+	for _, name := range names {
+		for _, k := range actions[name].Keys() {
+			if does, ok := listKeys[k]; ok {
+				return keys, fmt.Errorf("keys: %q %s in lists and cannot be bound to %s", keyName(k), does, name)
+			}
+			if other, ok := boundTo[k]; ok {
+				return keys, fmt.Errorf("keys: %q is bound to both %s and %s", keyName(k), other, name)
+			}
+			boundTo[k] = name
+		}
+	}
+	return keys, nil
+}
+
+// keyList accepts either a single key or an array of keys.
+type keyList []string
+
+func (l *keyList) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		*l = keyList{keyValue(v)}
+	case []any:
+		*l = nil
+		for _, k := range v {
+			s, ok := k.(string)
+			if !ok {
+				return fmt.Errorf("key must be a string, got %v", k)
+			}
+			*l = append(*l, keyValue(s))
+		}
+	default:
+		return fmt.Errorf("keys must be a string or an array of strings, got %v", v)
+	}
+	return nil
+}
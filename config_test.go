@@ -0,0 +1,50 @@
+package sptui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		remap map[string]keyList
+		// err is part of the expected error, or empty if there is none.
+		err string
+	}{
+		{name: "no remapping", remap: nil},
+		{name: "single key", remap: map[string]keyList{"toggle": {"t"}}},
+		{name: "several keys", remap: map[string]keyList{"next": {">", "L"}}},
+		{name: "swapped keys", remap: map[string]keyList{"next": {"<"}, "prev": {">"}}},
+		{name: "disabled", remap: map[string]keyList{"add_to_queue": {}}},
+		{name: "unknown action", remap: map[string]keyList{"rewind": {"w"}}, err: `unknown action "rewind"`},
+		{name: "taken by a default", remap: map[string]keyList{"next": {"<"}}, err: `"<" is bound to both next and prev`},
+		{name: "taken by another remap", remap: map[string]keyList{"device": {"f"}, "queue": {"f"}}, err: `"f" is bound to both device and queue`},
+		{name: "taken by the list", remap: map[string]keyList{"queue": {"g"}}, err: `"g" jumps to the first entry in lists`},
+		{name: "space", remap: map[string]keyList{"add_to_queue": {" "}}, err: `"space" is bound to both add_to_queue and toggle`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := applyKeys(DefaultKeyMap(), tt.remap)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions := keys.actions()
+			for name, want := range tt.remap {
+				b := actions[name]
+				if got := b.Keys(); strings.Join(got, " ") != strings.Join(want, " ") {
+					t.Errorf("%s is bound to %q, want %q", name, got, want)
+				}
+				if b.Enabled() != (len(want) > 0) {
+					t.Errorf("%s enabled = %v with keys %q", name, b.Enabled(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package sptui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmb3/spotify/v2"
+)
+
+// ControlAction is something another program can ask the TUI to do.
+type ControlAction int
+
+const (
+	ControlPlay ControlAction = iota
+	ControlPause
+	ControlPlayPause
+	ControlNext
+	ControlPrevious
+	// ControlSeek seeks to Position, or by Position if Relative is set.
+	ControlSeek
+	// ControlVolume sets the volume to Volume percent.
+	ControlVolume
+	// ControlShuffle turns shuffle on or off according to Shuffle.
+	ControlShuffle
+	// ControlRepeat sets the repeat mode to Repeat: off, context or track.
+	ControlRepeat
+	// ControlPlayURI plays URI, see PlayURIOptions.
+	ControlPlayURI
+	// ControlQueue adds URI to the queue.
+	ControlQueue
+	// ControlSearch opens the search tab with the results for Query, and
+	// replies with the *spotify.SearchResult.
+	ControlSearch
+)
+
+// ControlMsg asks the TUI to act on the player for another program, such as
+// a desktop media key. Send it with tea.Program.Send.
+type ControlMsg struct {
+	Action   ControlAction
+	Position time.Duration
+	Relative bool
+	Volume   int
+	Shuffle  bool
+	Repeat   string
+	URI      spotify.URI
+	Query    string
+
+	// Reply, if set, is called once the request has been carried out, from
+	// another goroutine.
+	Reply func(result any, err error)
+}
+
+var errNotLoggedIn = errors.New("sptui is not logged in")
+
+// PlayerObserver is told about every player state the TUI receives. It is
+// called from the TUI's update loop and must not block.
+type PlayerObserver interface {
+	PlayerChanged(np NowPlaying)
+}
+
+// PlayURIOptions returns the options that play uri: a track or episode on
+// its own, or an album, playlist, artist or show from the start.
+func PlayURIOptions(uri spotify.URI) (*spotify.PlayOptions, error) {
+	kind, _, _ := strings.Cut(strings.TrimPrefix(string(uri), "spotify:"), ":")
+	if !strings.HasPrefix(string(uri), "spotify:") || kind == "" {
+		return nil, fmt.Errorf("invalid Spotify URI %q", uri)
+	}
+
+	switch kind {
+	case "track", "episode":
+		return &spotify.PlayOptions{URIs: []spotify.URI{uri}}, nil
+	}
+	return &spotify.PlayOptions{PlaybackContext: &uri}, nil
+}
+
+// control carries out a ControlMsg with the same commands as the key
+// bindings.
+func control(m TabModel, msg ControlMsg) (tea.Model, tea.Cmd) {
+	model, cmd := controlCmd(m, msg)
+	if msg.Reply == nil {
+		return model, cmd
+	}
+	if cmd == nil {
+		msg.Reply(nil, nil)
+		return model, nil
+	}
+	return model, func() tea.Msg {
+		result := cmd()
+		switch r := result.(type) {
+		case ErrMsg:
+			msg.Reply(nil, r.Err)
+		case SearchMsg:
+			msg.Reply(r.Result, nil)
+		default:
+			msg.Reply(nil, nil)
+		}
+		return result
+	}
+}
+
+func controlCmd(m TabModel, msg ControlMsg) (tea.Model, tea.Cmd) {
+	switch msg.Action {
+	case ControlPlay:
+		return resumePlayback(m)
+	case ControlPause:
+		return m, PausePlaybackCmd(m.client)
+	case ControlPlayPause:
+		return togglePlayback(m)
+	case ControlNext:
+		return m, NextPlaybackCmd(m.client)
+	case ControlPrevious:
+		return m, PreviousPlaybackCmd(m.client)
+	case ControlSeek:
+		if msg.Relative {
+			return seekBy(m, msg.Position)
+		}
+		return m, SeekCmd(m.client, max(int(msg.Position.Milliseconds()), 0))
+	case ControlVolume:
+		return m, VolumeCmd(m.client, min(max(msg.Volume, 0), 100))
+	case ControlShuffle:
+		return m, ShuffleCmd(m.client, msg.Shuffle)
+	case ControlRepeat:
+		return m, RepeatCmd(m.client, msg.Repeat)
+	case ControlPlayURI:
+		opt, err := PlayURIOptions(msg.URI)
+		if err != nil {
+			return m, errCmd(err)
+		}
+		if m.currentDevice != nil {
+			opt.DeviceID = &m.currentDevice.ID
+		}
+		return m, StartPlaybackCmd(m.client, opt)
+	case ControlQueue:
+		if _, err := PlayURIOptions(msg.URI); err != nil {
+			return m, errCmd(err)
+		}
+		return m, QueueItemCmd(m.client, msg.URI)
+	case ControlSearch:
+		if strings.TrimSpace(msg.Query) == "" {
+			return m, errCmd(errors.New("search: empty query"))
+		}
+		return startSearch(m, msg.Query)
+	}
+	return m, nil
+}
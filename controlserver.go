@@ -0,0 +1,426 @@
+package sptui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmb3/spotify/v2"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+const (
+	// controlTimeout bounds how long a request waits for the TUI.
+	controlTimeout = 30 * time.Second
+	// controlBacklog is how many messages may wait for a client to read
+	// them before it is disconnected.
+	controlBacklog = 64
+)
+
+// ControlSocketPath returns where the control socket is served:
+// SPTUI_SOCKET if set, or sptui.sock in XDG_RUNTIME_DIR or the temporary
+// directory.
+func ControlSocketPath() string {
+	if p := os.Getenv("SPTUI_SOCKET"); p != "" {
+		return p
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "sptui.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("sptui-%d.sock", os.Getuid()))
+}
+
+// ControlServer serves JSON-RPC 2.0 on a Unix socket, one request or
+// response per line, so that other programs can drive the running TUI. The
+// methods are play, pause, next, prev, queue, search, status and subscribe.
+// After subscribe, the connection is sent a status notification whenever
+// the player changes. Register it with WithPlayerObserver and call Start
+// once the tea.Program exists.
+type ControlServer struct {
+	mu       sync.Mutex
+	listener net.Listener
+	send     func(tea.Msg)
+	np       NowPlaying
+	updated  time.Time
+	// subscribers are the connections that asked for notifications.
+	subscribers map[*controlConn]bool
+}
+
+func NewControlServer() *ControlServer {
+	return &ControlServer{subscribers: map[*controlConn]bool{}}
+}
+
+// Start listens on path and passes requests to send, which is usually
+// tea.Program.Send. It fails if another sptui is serving path.
+func (s *ControlServer) Start(path string, send func(tea.Msg)) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another sptui", path)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return fmt.Errorf("%s exists and is not a socket", path)
+		}
+		// Left behind by an sptui that did not exit cleanly.
+		os.Remove(path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener, s.send = l, send
+	s.mu.Unlock()
+	go s.serve(l)
+	return nil
+}
+
+// Close stops listening and removes the socket.
+func (s *ControlServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// PlayerChanged notifies the subscribers when anything but the advancing
+// position has changed.
+func (s *ControlServer) PlayerChanged(np NowPlaying) {
+	s.mu.Lock()
+	prev := s.np
+	s.np, s.updated = np, time.Now()
+	subscribers := make([]*controlConn, 0, len(s.subscribers))
+	for c := range s.subscribers {
+		subscribers = append(subscribers, c)
+	}
+	s.mu.Unlock()
+
+	notification := rpcMessage{Method: "status", Params: np}
+	prev.PositionMs, np.PositionMs = 0, 0
+	if prev == np {
+		return
+	}
+	for _, c := range subscribers {
+		c.write(notification)
+	}
+}
+
+func (s *ControlServer) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(newControlConn(conn))
+	}
+}
+
+func (s *ControlServer) handle(c *controlConn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, c)
+		s.mu.Unlock()
+		c.finish()
+	}()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			c.write(rpcMessage{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		if req.Method == "" {
+			c.write(rpcMessage{ID: req.ID, Error: &rpcError{Code: rpcInvalidRequest, Message: "missing method"}})
+			continue
+		}
+
+		result, rpcErr := s.call(c, req)
+		// Requests without an ID are notifications and get no response.
+		if req.ID == nil {
+			continue
+		}
+		if rpcErr != nil {
+			c.write(rpcMessage{ID: req.ID, Error: rpcErr})
+		} else {
+			c.write(rpcMessage{ID: req.ID, Result: result})
+		}
+	}
+}
+
+func (s *ControlServer) call(c *controlConn, req rpcRequest) (any, *rpcError) {
+	var params struct {
+		URI   spotify.URI `json:"uri"`
+		Query string      `json:"query"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	invalid := func(msg string) *rpcError {
+		return &rpcError{Code: rpcInvalidParams, Message: msg}
+	}
+
+	switch req.Method {
+	case "play":
+		if params.URI != "" {
+			return s.control(ControlMsg{Action: ControlPlayURI, URI: params.URI})
+		}
+		return s.control(ControlMsg{Action: ControlPlay})
+	case "pause":
+		return s.control(ControlMsg{Action: ControlPause})
+	case "next":
+		return s.control(ControlMsg{Action: ControlNext})
+	case "prev":
+		return s.control(ControlMsg{Action: ControlPrevious})
+	case "queue":
+		if params.URI == "" {
+			return nil, invalid("queue needs a uri")
+		}
+		return s.control(ControlMsg{Action: ControlQueue, URI: params.URI})
+	case "search":
+		if params.Query == "" {
+			return nil, invalid("search needs a query")
+		}
+		result, err := s.control(ControlMsg{Action: ControlSearch, Query: params.Query})
+		if err != nil {
+			return nil, err
+		}
+		r, ok := result.(*spotify.SearchResult)
+		if !ok {
+			return nil, &rpcError{Code: rpcServerError, Message: "search returned no results"}
+		}
+		return searchHits(r), nil
+	case "status":
+		return s.status(), nil
+	case "subscribe":
+		s.mu.Lock()
+		s.subscribers[c] = true
+		s.mu.Unlock()
+		return s.status(), nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + req.Method}
+}
+
+// control sends msg to the TUI and waits for it to be carried out.
+func (s *ControlServer) control(msg ControlMsg) (any, *rpcError) {
+	type reply struct {
+		result any
+		err    error
+	}
+	replies := make(chan reply, 1)
+	msg.Reply = func(result any, err error) {
+		replies <- reply{result, err}
+	}
+
+	s.mu.Lock()
+	send := s.send
+	s.mu.Unlock()
+	send(msg)
+
+	select {
+	case r := <-replies:
+		if r.err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: r.err.Error()}
+		}
+		if r.result == nil {
+			return true, nil
+		}
+		return r.result, nil
+	case <-time.After(controlTimeout):
+		return nil, &rpcError{Code: rpcServerError, Message: "sptui did not respond"}
+	}
+}
+
+func (s *ControlServer) status() NowPlaying {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.np.after(time.Since(s.updated))
+}
+
+// searchHit is an entry of the search results returned over the socket.
+type searchHit struct {
+	Type   string      `json:"type"`
+	Name   string      `json:"name"`
+	Artist string      `json:"artist,omitempty"`
+	URI    spotify.URI `json:"uri"`
+}
+
+func searchHits(r *spotify.SearchResult) []searchHit {
+	hits := []searchHit{}
+	if r == nil {
+		return hits
+	}
+	if r.Tracks != nil {
+		for _, t := range r.Tracks.Tracks {
+			hits = append(hits, searchHit{"track", t.Name, artistNames(t.Artists), t.URI})
+		}
+	}
+	if r.Albums != nil {
+		for _, a := range r.Albums.Albums {
+			hits = append(hits, searchHit{"album", a.Name, artistNames(a.Artists), a.URI})
+		}
+	}
+	if r.Artists != nil {
+		for _, a := range r.Artists.Artists {
+			hits = append(hits, searchHit{"artist", a.Name, "", a.URI})
+		}
+	}
+	if r.Playlists != nil {
+		for _, p := range r.Playlists.Playlists {
+			hits = append(hits, searchHit{"playlist", p.Name, p.Owner.DisplayName, p.URI})
+		}
+	}
+	if r.Shows != nil {
+		for _, s := range r.Shows.Shows {
+			hits = append(hits, searchHit{"show", s.Name, s.Publisher, s.URI})
+		}
+	}
+	return hits
+}
+
+func artistNames(artists []spotify.SimpleArtist) string {
+	var names []string
+	for _, a := range artists {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcMessage is a JSON-RPC response or notification.
+type rpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// controlConn is a client of the control socket. Responses and
+// notifications are queued from different goroutines, including the TUI's
+// update loop, and written by a goroutine of its own so that a client that
+// stops reading holds up no one else.
+type controlConn struct {
+	conn net.Conn
+	out  chan []byte
+	// closing is closed when the client has stopped sending, and done when
+	// the connection is closed.
+	closing    chan struct{}
+	done       chan struct{}
+	finishOnce sync.Once
+	closeOnce  sync.Once
+}
+
+func newControlConn(conn net.Conn) *controlConn {
+	c := &controlConn{
+		conn:    conn,
+		out:     make(chan []byte, controlBacklog),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// write queues msg without blocking. A client that has fallen
+// controlBacklog messages behind is disconnected.
+func (c *controlConn) write(msg rpcMessage) {
+	msg.Version = "2.0"
+	b, err := json.Marshal(msg)
+	if err != nil {
+		b, _ = json.Marshal(rpcMessage{Version: "2.0", ID: msg.ID, Error: &rpcError{Code: rpcServerError, Message: err.Error()}})
+	}
+
+	select {
+	case <-c.done:
+	case c.out <- append(b, '\n'):
+	default:
+		c.close()
+	}
+}
+
+func (c *controlConn) writeLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case b := <-c.out:
+			if !c.send(b) {
+				return
+			}
+		case <-c.closing:
+			// Write the responses that are still queued, then hang up.
+			for {
+				select {
+				case b := <-c.out:
+					if !c.send(b) {
+						return
+					}
+				default:
+					c.close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *controlConn) send(b []byte) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write(b); err != nil {
+		c.close()
+		return false
+	}
+	return true
+}
+
+// finish closes the connection once the queued messages are written.
+func (c *controlConn) finish() {
+	c.finishOnce.Do(func() { close(c.closing) })
+}
+
+func (c *controlConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
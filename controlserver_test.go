@@ -0,0 +1,130 @@
+package sptui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestControlServerHandle(t *testing.T) {
+	// The TUI fails to pause, finds nothing for "nothing" and carries out
+	// everything else.
+	sent := make(chan ControlMsg, 1)
+	send := func(msg tea.Msg) {
+		c := msg.(ControlMsg)
+		sent <- c
+		switch {
+		case c.Action == ControlPause:
+			c.Reply(nil, errors.New("no active device"))
+		case c.Action == ControlSearch && c.Query != "nothing":
+			c.Reply(&spotify.SearchResult{Tracks: &spotify.FullTrackPage{Tracks: []spotify.FullTrack{{
+				SimpleTrack: spotify.SimpleTrack{
+					Name:    "Solar Wind",
+					URI:     "spotify:track:fakealbum1t3",
+					Artists: []spotify.SimpleArtist{{Name: "The Aurora Band"}},
+				},
+			}}}}, nil)
+		default:
+			c.Reply(nil, nil)
+		}
+	}
+	s := NewControlServer()
+	path := filepath.Join(t.TempDir(), "sptui.sock")
+	if err := s.Start(path, send); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.PlayerChanged(NowPlaying{Type: "track", Title: "Solar Wind", URI: "spotify:track:fakealbum1t3", PositionMs: 1000, DurationMs: 200000, Volume: 50})
+
+	tests := []struct {
+		name string
+		req  string
+		// sent is the control message expected to reach the TUI, if any.
+		sent *ControlMsg
+		// result is the JSON of the expected result, or empty for an error
+		// with code, or for no response at all.
+		result string
+		code   int
+	}{
+		{name: "play", req: `{"jsonrpc":"2.0","id":1,"method":"play"}`, sent: &ControlMsg{Action: ControlPlay}, result: `true`},
+		{name: "play uri", req: `{"jsonrpc":"2.0","id":1,"method":"play","params":{"uri":"spotify:album:fakealbum1"}}`, sent: &ControlMsg{Action: ControlPlayURI, URI: "spotify:album:fakealbum1"}, result: `true`},
+		{name: "next", req: `{"jsonrpc":"2.0","id":"a","method":"next"}`, sent: &ControlMsg{Action: ControlNext}, result: `true`},
+		{name: "prev", req: `{"jsonrpc":"2.0","id":1,"method":"prev"}`, sent: &ControlMsg{Action: ControlPrevious}, result: `true`},
+		{name: "queue", req: `{"jsonrpc":"2.0","id":1,"method":"queue","params":{"uri":"spotify:track:fakealbum1t2"}}`, sent: &ControlMsg{Action: ControlQueue, URI: "spotify:track:fakealbum1t2"}, result: `true`},
+		{name: "search", req: `{"jsonrpc":"2.0","id":1,"method":"search","params":{"query":"solar"}}`, sent: &ControlMsg{Action: ControlSearch, Query: "solar"}, result: `[{"type":"track","name":"Solar Wind","artist":"The Aurora Band","uri":"spotify:track:fakealbum1t3"}]`},
+		{name: "status", req: `{"jsonrpc":"2.0","id":1,"method":"status"}`, result: `{"playing":false,"type":"track","title":"Solar Wind","uri":"spotify:track:fakealbum1t3","position_ms":1000,"duration_ms":200000,"volume":50,"shuffle":false}`},
+		{name: "notification", req: `{"jsonrpc":"2.0","method":"next"}`, sent: &ControlMsg{Action: ControlNext}},
+		{name: "failed", req: `{"jsonrpc":"2.0","id":1,"method":"pause"}`, sent: &ControlMsg{Action: ControlPause}, code: rpcServerError},
+		{name: "search without results", req: `{"jsonrpc":"2.0","id":1,"method":"search","params":{"query":"nothing"}}`, sent: &ControlMsg{Action: ControlSearch, Query: "nothing"}, code: rpcServerError},
+		{name: "not JSON", req: `{"jsonrpc":"2.0",`, code: rpcParseError},
+		{name: "no method", req: `{"jsonrpc":"2.0","id":1}`, code: rpcInvalidRequest},
+		{name: "unknown method", req: `{"jsonrpc":"2.0","id":1,"method":"rewind"}`, code: rpcMethodNotFound},
+		{name: "params not an object", req: `{"jsonrpc":"2.0","id":1,"method":"play","params":["spotify:album:fakealbum1"]}`, code: rpcInvalidParams},
+		{name: "queue without uri", req: `{"jsonrpc":"2.0","id":1,"method":"queue"}`, code: rpcInvalidParams},
+		{name: "search without query", req: `{"jsonrpc":"2.0","id":1,"method":"search","params":{}}`, code: rpcInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.Write([]byte(tt.req + "\n"))
+			conn.(*net.UnixConn).CloseWrite()
+
+			var responses [][]byte
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				responses = append(responses, bytes.Clone(scanner.Bytes()))
+			}
+
+			select {
+			case got := <-sent:
+				if tt.sent == nil {
+					t.Errorf("sent %+v to the TUI", got)
+				} else if got.Action != tt.sent.Action || got.URI != tt.sent.URI || got.Query != tt.sent.Query {
+					t.Errorf("sent %+v to the TUI, want %+v", got, *tt.sent)
+				}
+			default:
+				if tt.sent != nil {
+					t.Errorf("sent nothing to the TUI, want %+v", *tt.sent)
+				}
+			}
+
+			if tt.result == "" && tt.code == 0 {
+				if len(responses) != 0 {
+					t.Errorf("got %q, want no response", responses)
+				}
+				return
+			}
+			if len(responses) != 1 {
+				t.Fatalf("got %q, want one response", responses)
+			}
+			var resp struct {
+				Version string          `json:"jsonrpc"`
+				Result  json.RawMessage `json:"result"`
+				Error   *rpcError       `json:"error"`
+			}
+			if err := json.Unmarshal(responses[0], &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Version != "2.0" {
+				t.Errorf("jsonrpc is %q", resp.Version)
+			}
+			switch {
+			case tt.code != 0 && (resp.Error == nil || resp.Error.Code != tt.code):
+				t.Errorf("got %s, want error %d", responses[0], tt.code)
+			case tt.code == 0 && string(resp.Result) != tt.result:
+				t.Errorf("got %s, want result %s", responses[0], tt.result)
+			}
+		})
+	}
+}
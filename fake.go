@@ -0,0 +1,865 @@
+package sptui
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const fakePageSize = 20
+
+var (
+	errFakeNotFound       = spotify.Error{Status: http.StatusNotFound, Message: "Non existing id"}
+	errFakeNoActiveDevice = spotify.Error{Status: http.StatusNotFound, Message: "Player command failed: No active device found"}
+	errFakeBadOffset      = spotify.Error{Status: http.StatusBadRequest, Message: "Invalid offset"}
+)
+
+// FakeBackend is an in-memory Backend with a canned library and a simulated
+// player. It is safe for concurrent use.
+type FakeBackend struct {
+	mu sync.Mutex
+
+	// PageSize is the number of items returned by the library calls.
+	PageSize int
+	// Now is used to advance playback. Replace it to make progress deterministic.
+	Now func() time.Time
+
+	albums    []spotify.SavedAlbum
+	playlists []spotify.FullPlaylist
+	shows     []spotify.SavedShow
+	devices   []spotify.PlayerDevice
+
+	tracks   map[spotify.URI]spotify.FullTrack
+	episodes map[spotify.URI]spotify.EpisodePage
+	contexts map[spotify.URI][]spotify.URI
+	// resume holds the saved position of every episode that has been played.
+	resume map[spotify.URI]spotify.ResumePointObject
+
+	queue []spotify.URI
+	index int
+	// queued counts the items added with QueueItem that sit right after index.
+	queued    int
+	context   spotify.URI
+	playing   bool
+	progress  int
+	updatedAt time.Time
+	shuffle   bool
+	repeat    string
+}
+
+// NewFakeBackend returns a FakeBackend populated with a small library and
+// two devices, nothing playing.
+func NewFakeBackend() *FakeBackend {
+	f := &FakeBackend{
+		PageSize: fakePageSize,
+		Now:      time.Now,
+		repeat:   "off",
+		tracks:   map[spotify.URI]spotify.FullTrack{},
+		episodes: map[spotify.URI]spotify.EpisodePage{},
+		contexts: map[spotify.URI][]spotify.URI{},
+		resume:   map[spotify.URI]spotify.ResumePointObject{},
+		devices: []spotify.PlayerDevice{
+			{ID: "fakedevice1", Name: "Living Room", Type: "Speaker", Active: true, Volume: 60},
+			{ID: "fakedevice2", Name: "Laptop", Type: "Computer", Volume: 40},
+		},
+	}
+
+	f.AddAlbum(fakeAlbum("fakealbum1", "Northern Lights", "The Aurora Band",
+		"Polar Night", "Magnetic Field", "Solar Wind", "Green Curtain"))
+	f.AddAlbum(fakeAlbum("fakealbum2", "Harbour Songs", "Mira Okada",
+		"Tide Table", "Lighthouse", "Fog Horn"))
+	f.AddAlbum(fakeAlbum("fakealbum3", "夜明けの街", "Shiori Tanaka",
+		"始発電車", "坂道", "朝焼け", "ただいま", "おやすみ"))
+
+	f.AddPlaylist(fakePlaylist("fakeplaylist1", "Morning Coffee", f.albums[0].Tracks.Tracks[:2], f.albums[2].Tracks.Tracks[1:3]))
+	f.AddPlaylist(fakePlaylist("fakeplaylist2", "Late Night Drive", f.albums[1].Tracks.Tracks, f.albums[0].Tracks.Tracks[2:]))
+	var everything [][]spotify.SimpleTrack
+	for i := 0; i < 3; i++ {
+		for _, a := range f.albums {
+			everything = append(everything, a.Tracks.Tracks)
+		}
+	}
+	// Longer than a page, so that the track list has to be paged.
+	f.AddPlaylist(fakePlaylist("fakeplaylist3", "Everything Thrice", everything...))
+
+	f.AddShow(fakeShow("fakeshow1", "Terminal Velocity", "A show about command line tools",
+		"Episode 1: Pipes", "Episode 2: Signals", "Episode 3: Job Control"))
+	f.AddShow(fakeShow("fakeshow2", "Slow Cooking", "Recipes that take all day",
+		"Braised Short Ribs", "Sourdough Basics"))
+	var standups []string
+	for i := 1; i <= 30; i++ {
+		standups = append(standups, fmt.Sprintf("Standup #%d", i))
+	}
+	// Longer than a page, so that the episode list has to be paged.
+	f.AddShow(fakeShow("fakeshow3", "Daily Standup", "Fifteen minutes every weekday", standups...))
+
+	return f
+}
+
+// AddAlbum appends an album to the saved albums and makes it playable.
+func (f *FakeBackend) AddAlbum(album spotify.FullAlbum) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var uris []spotify.URI
+	for _, t := range album.Tracks.Tracks {
+		f.tracks[t.URI] = spotify.FullTrack{SimpleTrack: t, Album: album.SimpleAlbum}
+		uris = append(uris, t.URI)
+	}
+	f.contexts[album.URI] = uris
+	for _, artist := range album.Artists {
+		f.contexts[artist.URI] = append(f.contexts[artist.URI], uris...)
+	}
+	f.albums = append(f.albums, spotify.SavedAlbum{FullAlbum: album})
+}
+
+// AddPlaylist appends a playlist to the user's playlists and makes it playable.
+func (f *FakeBackend) AddPlaylist(playlist spotify.FullPlaylist) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var uris []spotify.URI
+	for _, t := range playlist.Tracks.Tracks {
+		f.tracks[t.Track.URI] = t.Track
+		uris = append(uris, t.Track.URI)
+	}
+	f.contexts[playlist.URI] = uris
+	f.playlists = append(f.playlists, playlist)
+}
+
+// AddShow appends a show to the saved shows and makes its episodes playable.
+func (f *FakeBackend) AddShow(show spotify.FullShow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var uris []spotify.URI
+	for _, e := range show.Episodes.Episodes {
+		e.Show = show.SimpleShow
+		f.episodes[e.URI] = e
+		uris = append(uris, e.URI)
+	}
+	f.contexts[show.URI] = uris
+	f.shows = append(f.shows, spotify.SavedShow{FullShow: show})
+}
+
+func (f *FakeBackend) CurrentUsersAlbums(_ context.Context, offset int) (*spotify.SavedAlbumPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start, end := f.pageBounds(offset, len(f.albums))
+	page := &spotify.SavedAlbumPage{Albums: f.albums[start:end]}
+	page.Offset, page.Limit, page.Total, page.Next = start, f.PageSize, len(f.albums), fakeNext(end, len(f.albums))
+	return page, nil
+}
+
+func (f *FakeBackend) CurrentUsersPlaylists(_ context.Context, offset int) (*spotify.SimplePlaylistPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start, end := f.pageBounds(offset, len(f.playlists))
+	page := &spotify.SimplePlaylistPage{}
+	for _, p := range f.playlists[start:end] {
+		page.Playlists = append(page.Playlists, p.SimplePlaylist)
+	}
+	page.Offset, page.Limit, page.Total, page.Next = start, f.PageSize, len(f.playlists), fakeNext(end, len(f.playlists))
+	return page, nil
+}
+
+func (f *FakeBackend) CurrentUsersShows(_ context.Context, offset int) (*spotify.SavedShowPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start, end := f.pageBounds(offset, len(f.shows))
+	page := &spotify.SavedShowPage{Shows: f.shows[start:end]}
+	page.Offset, page.Limit, page.Total, page.Next = start, f.PageSize, len(f.shows), fakeNext(end, len(f.shows))
+	return page, nil
+}
+
+func (f *FakeBackend) GetAlbum(_ context.Context, id spotify.ID) (*spotify.FullAlbum, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, a := range f.albums {
+		if a.ID == id {
+			album := a.FullAlbum
+			album.Tracks = f.albumTrackPage(a.FullAlbum, 0)
+			return &album, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+func (f *FakeBackend) GetAlbumTracks(_ context.Context, id spotify.ID, offset int) (*spotify.SimpleTrackPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, a := range f.albums {
+		if a.ID == id {
+			page := f.albumTrackPage(a.FullAlbum, offset)
+			return &page, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+func (f *FakeBackend) GetPlaylist(_ context.Context, id spotify.ID) (*spotify.FullPlaylist, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range f.playlists {
+		if p.ID == id {
+			playlist := p
+			playlist.Tracks = f.playlistTrackPage(p, 0)
+			return &playlist, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+func (f *FakeBackend) GetPlaylistTracks(_ context.Context, id spotify.ID, offset int) (*spotify.PlaylistTrackPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range f.playlists {
+		if p.ID == id {
+			page := f.playlistTrackPage(p, offset)
+			return &page, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+func (f *FakeBackend) GetShow(_ context.Context, id spotify.ID) (*spotify.FullShow, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.shows {
+		if s.ID == id {
+			show := s.FullShow
+			show.Episodes = f.episodePage(show.URI, 0)
+			return &show, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+// GetShowEpisodes pages through the episodes of a show, with the saved
+// resume points.
+func (f *FakeBackend) GetShowEpisodes(_ context.Context, id spotify.ID, offset int) (*spotify.SimpleEpisodePage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.shows {
+		if s.ID == id {
+			page := f.episodePage(s.URI, offset)
+			return &page, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+func (f *FakeBackend) GetEpisode(_ context.Context, id spotify.ID) (*spotify.EpisodePage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	e, ok := f.episodes[spotify.URI("spotify:episode:"+id)]
+	if !ok {
+		return nil, errFakeNotFound
+	}
+	e.ResumePoint = f.resume[e.URI]
+	return &e, nil
+}
+
+func (f *FakeBackend) albumTrackPage(album spotify.FullAlbum, offset int) spotify.SimpleTrackPage {
+	tracks := album.Tracks.Tracks
+	start, end := f.pageBounds(offset, len(tracks))
+	page := spotify.SimpleTrackPage{Tracks: tracks[start:end]}
+	page.Offset, page.Limit, page.Total, page.Next = start, f.PageSize, len(tracks), fakeNext(end, len(tracks))
+	return page
+}
+
+func (f *FakeBackend) playlistTrackPage(playlist spotify.FullPlaylist, offset int) spotify.PlaylistTrackPage {
+	tracks := playlist.Tracks.Tracks
+	start, end := f.pageBounds(offset, len(tracks))
+	page := spotify.PlaylistTrackPage{Tracks: tracks[start:end]}
+	page.Offset, page.Limit, page.Total, page.Next = start, f.PageSize, len(tracks), fakeNext(end, len(tracks))
+	return page
+}
+
+func (f *FakeBackend) episodePage(show spotify.URI, offset int) spotify.SimpleEpisodePage {
+	uris := f.contexts[show]
+	start, end := f.pageBounds(offset, len(uris))
+	var page spotify.SimpleEpisodePage
+	for _, uri := range uris[start:end] {
+		e := f.episodes[uri]
+		e.ResumePoint = f.resume[uri]
+		page.Episodes = append(page.Episodes, e)
+	}
+	page.Offset, page.Limit, page.Total, page.Next = start, f.PageSize, len(uris), fakeNext(end, len(uris))
+	return page
+}
+
+// Search does a case-insensitive substring match on names. Each result type
+// is paged independently from offset.
+func (f *FakeBackend) Search(_ context.Context, query string, offset int) (*spotify.SearchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	q := strings.ToLower(query)
+	match := func(name string) bool {
+		return strings.Contains(strings.ToLower(name), q)
+	}
+
+	var (
+		tracks    []spotify.FullTrack
+		albums    []spotify.SimpleAlbum
+		artists   []spotify.FullArtist
+		playlists []spotify.SimplePlaylist
+		shows     []spotify.FullShow
+		seen      = map[spotify.URI]bool{}
+	)
+	for _, a := range f.albums {
+		if match(a.Name) {
+			albums = append(albums, a.SimpleAlbum)
+		}
+		for _, artist := range a.Artists {
+			if match(artist.Name) && !seen[artist.URI] {
+				seen[artist.URI] = true
+				artists = append(artists, spotify.FullArtist{SimpleArtist: artist})
+			}
+		}
+		for _, t := range a.Tracks.Tracks {
+			if match(t.Name) && !seen[t.URI] {
+				seen[t.URI] = true
+				tracks = append(tracks, f.tracks[t.URI])
+			}
+		}
+	}
+	for _, p := range f.playlists {
+		if match(p.Name) {
+			playlists = append(playlists, p.SimplePlaylist)
+		}
+	}
+	for _, s := range f.shows {
+		if match(s.Name) {
+			shows = append(shows, s.FullShow)
+		}
+	}
+
+	res := &spotify.SearchResult{
+		Tracks:    &spotify.FullTrackPage{},
+		Albums:    &spotify.SimpleAlbumPage{},
+		Artists:   &spotify.FullArtistPage{},
+		Playlists: &spotify.SimplePlaylistPage{},
+		Shows:     &spotify.SimpleShowPage{},
+	}
+
+	start, end := f.pageBounds(offset, len(tracks))
+	res.Tracks.Tracks = tracks[start:end]
+	res.Tracks.Offset, res.Tracks.Limit, res.Tracks.Total, res.Tracks.Next = start, f.PageSize, len(tracks), fakeNext(end, len(tracks))
+
+	start, end = f.pageBounds(offset, len(albums))
+	res.Albums.Albums = albums[start:end]
+	res.Albums.Offset, res.Albums.Limit, res.Albums.Total, res.Albums.Next = start, f.PageSize, len(albums), fakeNext(end, len(albums))
+
+	start, end = f.pageBounds(offset, len(artists))
+	res.Artists.Artists = artists[start:end]
+	res.Artists.Offset, res.Artists.Limit, res.Artists.Total, res.Artists.Next = start, f.PageSize, len(artists), fakeNext(end, len(artists))
+
+	start, end = f.pageBounds(offset, len(playlists))
+	res.Playlists.Playlists = playlists[start:end]
+	res.Playlists.Offset, res.Playlists.Limit, res.Playlists.Total, res.Playlists.Next = start, f.PageSize, len(playlists), fakeNext(end, len(playlists))
+
+	start, end = f.pageBounds(offset, len(shows))
+	res.Shows.Shows = shows[start:end]
+	res.Shows.Offset, res.Shows.Limit, res.Shows.Total, res.Shows.Next = start, f.PageSize, len(shows), fakeNext(end, len(shows))
+
+	return res, nil
+}
+
+// PlayerCurrentlyPlaying mirrors the Web API with episodes requested: the item
+// is nil when nothing is loaded, and an episode comes squeezed into a track.
+func (f *FakeBackend) PlayerCurrentlyPlaying(_ context.Context) (*spotify.CurrentlyPlaying, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	cp := &spotify.CurrentlyPlaying{Timestamp: f.Now().UnixMilli()}
+	if len(f.queue) == 0 {
+		return cp, nil
+	}
+	cp.Playing = f.playing
+	cp.Progress = f.progress
+	if f.context != "" {
+		cp.PlaybackContext = spotify.PlaybackContext{URI: f.context}
+	}
+	if t, ok := f.itemAsTrack(f.queue[f.index]); ok {
+		cp.Item = &t
+	}
+	return cp, nil
+}
+
+// PlayerState returns nil when no device is active, like the Web API's 204.
+func (f *FakeBackend) PlayerState(ctx context.Context) (*spotify.PlayerState, error) {
+	cp, err := f.PlayerCurrentlyPlaying(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	device := f.activeDevice()
+	if device == nil {
+		return nil, nil
+	}
+	return &spotify.PlayerState{
+		CurrentlyPlaying: *cp,
+		Device:           *device,
+		ShuffleState:     f.shuffle,
+		RepeatState:      f.repeat,
+	}, nil
+}
+
+func (f *FakeBackend) PlayerDevices(_ context.Context) ([]spotify.PlayerDevice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]spotify.PlayerDevice(nil), f.devices...), nil
+}
+
+func (f *FakeBackend) PlayOpt(_ context.Context, opt *spotify.PlayOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if opt == nil {
+		opt = &spotify.PlayOptions{}
+	}
+	if opt.DeviceID != nil {
+		if err := f.activate(*opt.DeviceID); err != nil {
+			return err
+		}
+	}
+	if f.activeDevice() == nil {
+		return errFakeNoActiveDevice
+	}
+
+	var queue []spotify.URI
+	var playbackContext spotify.URI
+	switch {
+	case opt.PlaybackContext != nil:
+		uris, ok := f.contexts[*opt.PlaybackContext]
+		if !ok {
+			return errFakeNotFound
+		}
+		queue, playbackContext = uris, *opt.PlaybackContext
+	case len(opt.URIs) > 0:
+		for _, uri := range opt.URIs {
+			if f.duration(uri) == 0 {
+				return errFakeNotFound
+			}
+		}
+		queue = opt.URIs
+	default:
+		if len(f.queue) == 0 {
+			return errFakeNoActiveDevice
+		}
+		f.playing = true
+		return nil
+	}
+
+	index := 0
+	if opt.PlaybackOffset != nil {
+		if opt.PlaybackOffset.Position != nil {
+			index = *opt.PlaybackOffset.Position
+		} else {
+			index = -1
+			for i, uri := range queue {
+				if uri == opt.PlaybackOffset.URI {
+					index = i
+				}
+			}
+		}
+	}
+	if index < 0 || index >= len(queue) {
+		return errFakeBadOffset
+	}
+
+	f.queue = append([]spotify.URI(nil), queue...)
+	f.context = playbackContext
+	f.index = index
+	f.queued = 0
+	f.progress = opt.PositionMs
+	f.playing = true
+	return nil
+}
+
+func (f *FakeBackend) TransferPlayback(_ context.Context, id spotify.ID, play bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if err := f.activate(id); err != nil {
+		return err
+	}
+	if play && len(f.queue) > 0 {
+		f.playing = true
+	}
+	return nil
+}
+
+func (f *FakeBackend) Pause(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if len(f.queue) == 0 {
+		return errFakeNoActiveDevice
+	}
+	f.playing = false
+	return nil
+}
+
+func (f *FakeBackend) Next(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if len(f.queue) == 0 {
+		return errFakeNoActiveDevice
+	}
+	if f.index+1 < len(f.queue) {
+		f.advance()
+	}
+	f.progress = 0
+	return nil
+}
+
+func (f *FakeBackend) Previous(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if len(f.queue) == 0 {
+		return errFakeNoActiveDevice
+	}
+	if f.index > 0 {
+		f.index--
+	}
+	f.progress = 0
+	return nil
+}
+
+func (f *FakeBackend) Seek(_ context.Context, positionMs int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if len(f.queue) == 0 {
+		return errFakeNoActiveDevice
+	}
+	if positionMs < 0 {
+		return spotify.Error{Status: http.StatusBadRequest, Message: "Invalid position"}
+	}
+	// Seeking past the end skips to the next item, as on a real device.
+	f.progress = positionMs
+	if f.playing {
+		f.sync()
+	} else {
+		f.progress = min(f.progress, f.duration(f.queue[f.index]))
+	}
+	return nil
+}
+
+func (f *FakeBackend) Volume(_ context.Context, percent int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	device := f.activeDevice()
+	if device == nil {
+		return errFakeNoActiveDevice
+	}
+	if percent < 0 || percent > 100 {
+		return spotify.Error{Status: http.StatusBadRequest, Message: "Invalid volume"}
+	}
+	device.Volume = percent
+	return nil
+}
+
+func (f *FakeBackend) Shuffle(_ context.Context, shuffle bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.activeDevice() == nil {
+		return errFakeNoActiveDevice
+	}
+	f.shuffle = shuffle
+	return nil
+}
+
+func (f *FakeBackend) Repeat(_ context.Context, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.activeDevice() == nil {
+		return errFakeNoActiveDevice
+	}
+	switch state {
+	case "off", "context", "track":
+		f.repeat = state
+		return nil
+	default:
+		return spotify.Error{Status: http.StatusBadRequest, Message: "Invalid repeat state"}
+	}
+}
+
+func (f *FakeBackend) GetQueue(_ context.Context) (*spotify.Queue, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	q := &spotify.Queue{}
+	if len(f.queue) == 0 {
+		return q, nil
+	}
+	q.CurrentlyPlaying, _ = f.itemAsTrack(f.queue[f.index])
+	for _, uri := range f.queue[f.index+1:] {
+		t, _ := f.itemAsTrack(uri)
+		q.Items = append(q.Items, t)
+	}
+	return q, nil
+}
+
+// QueueItem inserts uri after the current item and anything queued before it.
+func (f *FakeBackend) QueueItem(_ context.Context, uri spotify.URI) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sync()
+	if len(f.queue) == 0 {
+		return errFakeNoActiveDevice
+	}
+	if f.duration(uri) == 0 {
+		return errFakeNotFound
+	}
+	pos := f.index + 1 + f.queued
+	f.queue = append(f.queue[:pos], append([]spotify.URI{uri}, f.queue[pos:]...)...)
+	f.queued++
+	return nil
+}
+
+func (f *FakeBackend) advance() {
+	f.index++
+	f.queued = max(f.queued-1, 0)
+}
+
+// itemAsTrack returns the track for uri, or an episode squeezed into a
+// FullTrack the way the Web API does in responses typed as tracks.
+func (f *FakeBackend) itemAsTrack(uri spotify.URI) (spotify.FullTrack, bool) {
+	if t, ok := f.tracks[uri]; ok {
+		return t, true
+	}
+	if e, ok := f.episodes[uri]; ok {
+		return spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{
+			ID:       e.ID,
+			Name:     e.Name,
+			URI:      e.URI,
+			Duration: e.Duration_ms,
+			Type:     "episode",
+		}}, true
+	}
+	return spotify.FullTrack{}, false
+}
+
+// sync advances the simulated playback to f.Now(). Callers must hold f.mu.
+func (f *FakeBackend) sync() {
+	now := f.Now()
+	if f.playing && len(f.queue) > 0 {
+		f.progress += int(now.Sub(f.updatedAt).Milliseconds())
+		for f.progress >= f.duration(f.queue[f.index]) {
+			if f.repeat == "track" {
+				f.progress -= f.duration(f.queue[f.index])
+				continue
+			}
+			f.markPlayed(f.queue[f.index])
+			if f.index+1 >= len(f.queue) && f.repeat == "context" {
+				f.progress -= f.duration(f.queue[f.index])
+				f.index, f.queued = 0, 0
+				continue
+			}
+			if f.index+1 >= len(f.queue) {
+				f.progress = f.duration(f.queue[f.index])
+				f.playing = false
+				break
+			}
+			f.progress -= f.duration(f.queue[f.index])
+			f.advance()
+		}
+	}
+	if len(f.queue) > 0 {
+		if _, ok := f.episodes[f.queue[f.index]]; ok {
+			rp := f.resume[f.queue[f.index]]
+			rp.ResumePositionMs = f.progress
+			f.resume[f.queue[f.index]] = rp
+		}
+	}
+	f.updatedAt = now
+}
+
+// markPlayed records that an episode has been listened to the end.
+func (f *FakeBackend) markPlayed(uri spotify.URI) {
+	if _, ok := f.episodes[uri]; ok {
+		f.resume[uri] = spotify.ResumePointObject{FullyPlayed: true}
+	}
+}
+
+func (f *FakeBackend) duration(uri spotify.URI) int {
+	if t, ok := f.tracks[uri]; ok {
+		return t.Duration
+	}
+	if e, ok := f.episodes[uri]; ok {
+		return e.Duration_ms
+	}
+	return 0
+}
+
+func (f *FakeBackend) activate(id spotify.ID) error {
+	found := false
+	for i := range f.devices {
+		if f.devices[i].ID == id {
+			found = true
+		}
+	}
+	if !found {
+		return spotify.Error{Status: http.StatusNotFound, Message: "Device not found"}
+	}
+	for i := range f.devices {
+		f.devices[i].Active = f.devices[i].ID == id
+	}
+	return nil
+}
+
+func (f *FakeBackend) activeDevice() *spotify.PlayerDevice {
+	for i := range f.devices {
+		if f.devices[i].Active {
+			return &f.devices[i]
+		}
+	}
+	return nil
+}
+
+func (f *FakeBackend) pageBounds(offset, total int) (int, int) {
+	size := f.PageSize
+	if size <= 0 {
+		size = fakePageSize
+	}
+	start := min(max(offset, 0), total)
+	return start, min(start+size, total)
+}
+
+func fakeNext(end, total int) string {
+	if end >= total {
+		return ""
+	}
+	return fmt.Sprintf("fake:next?offset=%d", end)
+}
+
+func fakeAlbum(id, name, artist string, trackNames ...string) spotify.FullAlbum {
+	album := spotify.FullAlbum{
+		SimpleAlbum: spotify.SimpleAlbum{
+			ID:          spotify.ID(id),
+			Name:        name,
+			URI:         spotify.URI("spotify:album:" + id),
+			Artists:     []spotify.SimpleArtist{fakeArtist(artist)},
+			AlbumType:   "album",
+			ReleaseDate: "2023",
+		},
+	}
+	for i, n := range trackNames {
+		trackID := fmt.Sprintf("%st%d", id, i+1)
+		album.Tracks.Tracks = append(album.Tracks.Tracks, spotify.SimpleTrack{
+			ID:          spotify.ID(trackID),
+			Name:        n,
+			URI:         spotify.URI("spotify:track:" + trackID),
+			Artists:     album.Artists,
+			Duration:    150000 + 15000*i,
+			TrackNumber: i + 1,
+			DiscNumber:  1,
+			Type:        "track",
+			Album:       album.SimpleAlbum,
+		})
+	}
+	album.Tracks.Total = len(trackNames)
+	album.Tracks.Limit = len(trackNames)
+	return album
+}
+
+func fakePlaylist(id, name string, sources ...[]spotify.SimpleTrack) spotify.FullPlaylist {
+	playlist := spotify.FullPlaylist{
+		SimplePlaylist: spotify.SimplePlaylist{
+			ID:         spotify.ID(id),
+			Name:       name,
+			URI:        spotify.URI("spotify:playlist:" + id),
+			Owner:      spotify.User{ID: "fakeuser", DisplayName: "Fake User"},
+			SnapshotID: id + "-snapshot",
+		},
+	}
+	for _, tracks := range sources {
+		for _, t := range tracks {
+			playlist.Tracks.Tracks = append(playlist.Tracks.Tracks, spotify.PlaylistTrack{
+				AddedAt: "2024-01-01T00:00:00Z",
+				Track:   spotify.FullTrack{SimpleTrack: t, Album: t.Album},
+			})
+		}
+	}
+	playlist.Tracks.Total = len(playlist.Tracks.Tracks)
+	playlist.Tracks.Limit = len(playlist.Tracks.Tracks)
+	playlist.SimplePlaylist.Tracks.Total = uint(len(playlist.Tracks.Tracks))
+	return playlist
+}
+
+func fakeShow(id, name, description string, episodeNames ...string) spotify.FullShow {
+	show := spotify.FullShow{
+		SimpleShow: spotify.SimpleShow{
+			ID:          spotify.ID(id),
+			Name:        name,
+			Description: description,
+			Publisher:   "Fake Publisher",
+			URI:         spotify.URI("spotify:show:" + id),
+			MediaType:   "audio",
+			Type:        "show",
+		},
+	}
+	for i, n := range episodeNames {
+		episodeID := fmt.Sprintf("%se%d", id, i+1)
+		show.Episodes.Episodes = append(show.Episodes.Episodes, spotify.EpisodePage{
+			ID:                   spotify.ID(episodeID),
+			Name:                 n,
+			URI:                  spotify.URI("spotify:episode:" + episodeID),
+			Duration_ms:          1800000 + 60000*i,
+			IsPlayable:           true,
+			ReleaseDate:          time.Date(2024, 1, 1+7*i, 0, 0, 0, 0, time.UTC).Format(time.DateOnly),
+			ReleaseDatePrecision: "day",
+			Type:                 "episode",
+		})
+	}
+	show.Episodes.Total = len(episodeNames)
+	show.Episodes.Limit = len(episodeNames)
+	return show
+}
+
+func fakeArtist(name string) spotify.SimpleArtist {
+	id := strings.ToLower(strings.ReplaceAll(name, " ", ""))
+	return spotify.SimpleArtist{
+		ID:   spotify.ID(id),
+		Name: name,
+		URI:  spotify.URI("spotify:artist:" + id),
+	}
+}
@@ -1,17 +1,187 @@
 package sptui
 
 import (
+	"strings"
+
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 )
 
 type KeyMap struct {
-	Play   key.Binding
-	Pause  key.Binding
-	Next   key.Binding
-	Prev   key.Binding
-	Help   key.Binding
-	Device key.Binding
+	Quit    key.Binding
+	NextTab key.Binding
+	PrevTab key.Binding
+	Up      key.Binding
+	Down    key.Binding
+	Select  key.Binding
+	Back    key.Binding
+	Filter  key.Binding
+
+	Toggle     key.Binding
+	Next       key.Binding
+	Prev       key.Binding
+	Help       key.Binding
+	Device     key.Binding
+	Queue      key.Binding
+	AddToQueue key.Binding
+	Command    key.Binding
+
+	SeekBackward key.Binding
+	SeekForward  key.Binding
+	VolumeDown   key.Binding
+	VolumeUp     key.Binding
+	Shuffle      key.Binding
+	Repeat       key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Quit: key.NewBinding(
+			key.WithKeys("ctrl+c", "q"),
+			key.WithHelp("q", "quit"),
+		),
+		NextTab: key.NewBinding(
+			key.WithKeys("l", "n", "tab", "right"),
+			key.WithHelp("l", "next tab"),
+		),
+		PrevTab: key.NewBinding(
+			key.WithKeys("h", "p", "shift+tab", "left"),
+			key.WithHelp("h", "prev tab"),
+		),
+		Up: key.NewBinding(
+			key.WithKeys("k", "up"),
+			key.WithHelp("k", "up"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("j", "down"),
+			key.WithHelp("j", "down"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "select"),
+		),
+		Back: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "back"),
+		),
+		Filter: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "filter"),
+		),
+		Toggle: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "play/pause"),
+		),
+		Next: key.NewBinding(
+			key.WithKeys(">"),
+			key.WithHelp(">", "next"),
+		),
+		Prev: key.NewBinding(
+			key.WithKeys("<"),
+			key.WithHelp("<", "prev"),
+		),
+		Help: key.NewBinding(
+			key.WithKeys("?"),
+			key.WithHelp("?", "help"),
+		),
+		Device: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "device"),
+		),
+		Queue: key.NewBinding(
+			key.WithKeys("u"),
+			key.WithHelp("u", "queue"),
+		),
+		AddToQueue: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "add to queue"),
+		),
+		Command: key.NewBinding(
+			key.WithKeys(":"),
+			key.WithHelp(":", "command"),
+		),
+		SeekBackward: key.NewBinding(
+			key.WithKeys("["),
+			key.WithHelp("[", "-10s"),
+		),
+		SeekForward: key.NewBinding(
+			key.WithKeys("]"),
+			key.WithHelp("]", "+10s"),
+		),
+		VolumeDown: key.NewBinding(
+			key.WithKeys("-"),
+			key.WithHelp("-", "volume down"),
+		),
+		VolumeUp: key.NewBinding(
+			key.WithKeys("+", "="),
+			key.WithHelp("+", "volume up"),
+		),
+		Shuffle: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "shuffle"),
+		),
+		Repeat: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "repeat"),
+		),
+		//TODO: add more keybindings
+	}
+}
+
+// actions maps the names used in the [keys] section of the config file to
+// the bindings they remap.
+func (k *KeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":          &k.Quit,
+		"next_tab":      &k.NextTab,
+		"prev_tab":      &k.PrevTab,
+		"up":            &k.Up,
+		"down":          &k.Down,
+		"select":        &k.Select,
+		"back":          &k.Back,
+		"filter":        &k.Filter,
+		"toggle":        &k.Toggle,
+		"next":          &k.Next,
+		"prev":          &k.Prev,
+		"device":        &k.Device,
+		"queue":         &k.Queue,
+		"add_to_queue":  &k.AddToQueue,
+		"command":       &k.Command,
+		"seek_backward": &k.SeekBackward,
+		"seek_forward":  &k.SeekForward,
+		"volume_down":   &k.VolumeDown,
+		"volume_up":     &k.VolumeUp,
+		"shuffle":       &k.Shuffle,
+		"repeat":        &k.Repeat,
+		"help":          &k.Help,
+	}
+}
+
+// setKeys rebinds b and updates its help text. An empty list disables b.
+func setKeys(b *key.Binding, keys ...string) {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = keyName(k)
+	}
+	b.SetKeys(keys...)
+	b.SetHelp(strings.Join(names, "/"), b.Help().Desc)
+	b.SetEnabled(len(keys) > 0)
+}
+
+// keyValue converts a key name from the config file to the form reported by
+// tea.KeyMsg.String.
+func keyValue(name string) string {
+	if name == "space" {
+		return " "
+	}
+	return name
+}
+
+func keyName(k string) string {
+	if k == " " {
+		return "space"
+	}
+	return k
 }
 
 type HelpModel struct {
@@ -19,66 +189,71 @@ type HelpModel struct {
 	KeyMap KeyMap
 }
 
-func NewHelp() HelpModel {
+func NewHelp(keys KeyMap) HelpModel {
 	help := help.New()
-	help.Width = 40
+	help.Width = 60
 	help.ShortSeparator = " "
 	return HelpModel{
-		help: help,
-		KeyMap: KeyMap{
-			Play: key.NewBinding(
-				key.WithKeys(""),
-				key.WithHelp(":play", ""),
-			),
-			Pause: key.NewBinding(
-				key.WithKeys(""),
-				key.WithHelp(":pause", ""),
-			),
-			Next: key.NewBinding(
-				key.WithKeys(""),
-				key.WithHelp(":next", ""),
-			),
-			Prev: key.NewBinding(
-				key.WithKeys(""),
-				key.WithHelp(":prev", ""),
-			),
-			Help: key.NewBinding(
-				key.WithKeys(""),
-				key.WithHelp(":help", ""),
-			),
-			Device: key.NewBinding(
-				key.WithKeys(""),
-				key.WithHelp(":device", ""),
-			),
-			//TODO: add more keybindings
-		},
+		help:   help,
+		KeyMap: keys,
 	}
 
 }
 
 func (m HelpModel) ShortHelp() []key.Binding {
 	return []key.Binding{
-		//TODO: help view
-		// m.KeyMap.Help,
-		m.KeyMap.Play,
-		m.KeyMap.Pause,
+		m.KeyMap.Toggle,
 		m.KeyMap.Next,
 		m.KeyMap.Prev,
 		m.KeyMap.Device,
+		m.KeyMap.Queue,
+		m.KeyMap.Help,
 	}
 }
 
 func (m HelpModel) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{
-			m.KeyMap.Play,
-			m.KeyMap.Pause,
+			m.KeyMap.Toggle,
 			m.KeyMap.Next,
 			m.KeyMap.Prev,
 			m.KeyMap.Device,
+			m.KeyMap.Queue,
+			m.KeyMap.AddToQueue,
+			m.KeyMap.Command,
 			m.KeyMap.Help,
 		},
+		{
+			m.KeyMap.Up,
+			m.KeyMap.Down,
+			m.KeyMap.NextTab,
+			m.KeyMap.PrevTab,
+			m.KeyMap.Select,
+			m.KeyMap.Back,
+			m.KeyMap.Filter,
+			m.KeyMap.Quit,
+		},
+		{
+			m.KeyMap.SeekBackward,
+			m.KeyMap.SeekForward,
+			m.KeyMap.VolumeDown,
+			m.KeyMap.VolumeUp,
+			m.KeyMap.Shuffle,
+			m.KeyMap.Repeat,
+		},
+	}
+}
+
+// height is the number of lines the help view takes.
+func (m HelpModel) height() int {
+	if !m.help.ShowAll {
+		return 1
+	}
+	h := 0
+	for _, column := range m.FullHelp() {
+		h = max(h, len(column))
 	}
+	return h
 }
 
 func (m HelpModel) View() string {
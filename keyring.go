@@ -0,0 +1,223 @@
+package sptui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/oauth2"
+)
+
+// The freedesktop Secret Service API, as implemented by GNOME Keyring and
+// KWallet. See https://specifications.freedesktop.org/secret-service/.
+const (
+	secretServiceName = "org.freedesktop.secrets"
+	secretServicePath = dbus.ObjectPath("/org/freedesktop/secrets")
+	defaultCollection = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+
+	secretServiceIface = "org.freedesktop.Secret.Service"
+	secretItemIface    = "org.freedesktop.Secret.Item"
+	secretPromptIface  = "org.freedesktop.Secret.Prompt"
+
+	keyringApplication = "sptui"
+)
+
+// secret is the Secret struct of the Secret Service API.
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// keyringStore keeps tokens in the Secret Service, one item per profile.
+type keyringStore struct{}
+
+func (keyringStore) Load(profile string) (*oauth2.Token, error) {
+	var token *oauth2.Token
+	err := withSecretService(func(s *secretService) error {
+		items, err := s.search(keyringAttributes(profile))
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("no token for profile %s in the keyring: %w", profile, fs.ErrNotExist)
+		}
+		var sec secret
+		err = s.conn.Object(secretServiceName, items[0]).
+			Call(secretItemIface+".GetSecret", 0, s.session).Store(&sec)
+		if err != nil {
+			return err
+		}
+		token, err = decodeToken(sec.Value)
+		return err
+	})
+	return token, err
+}
+
+func (keyringStore) Save(profile string, token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return withSecretService(func(s *secretService) error {
+		if err := s.unlock([]dbus.ObjectPath{defaultCollection}); err != nil {
+			return err
+		}
+		props := map[string]dbus.Variant{
+			secretItemIface + ".Label":      dbus.MakeVariant("sptui Spotify token (" + profile + ")"),
+			secretItemIface + ".Attributes": dbus.MakeVariant(keyringAttributes(profile)),
+		}
+		sec := secret{Session: s.session, Value: data, ContentType: "application/json"}
+		var item, prompt dbus.ObjectPath
+		err := s.conn.Object(secretServiceName, defaultCollection).
+			Call("org.freedesktop.Secret.Collection.CreateItem", 0, props, sec, true).
+			Store(&item, &prompt)
+		if err != nil {
+			return err
+		}
+		return s.prompt(prompt)
+	})
+}
+
+func (keyringStore) Delete(profile string) error {
+	return withSecretService(func(s *secretService) error {
+		items, err := s.search(keyringAttributes(profile))
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("no token for profile %s in the keyring: %w", profile, fs.ErrNotExist)
+		}
+		for _, item := range items {
+			var prompt dbus.ObjectPath
+			err := s.conn.Object(secretServiceName, item).Call(secretItemIface+".Delete", 0).Store(&prompt)
+			if err != nil {
+				return err
+			}
+			if err := s.prompt(prompt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (keyringStore) Profiles() ([]string, error) {
+	var names []string
+	err := withSecretService(func(s *secretService) error {
+		items, err := s.search(map[string]string{"application": keyringApplication})
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			v, err := s.conn.Object(secretServiceName, item).GetProperty(secretItemIface + ".Attributes")
+			if err != nil {
+				return err
+			}
+			if attrs, ok := v.Value().(map[string]string); ok && attrs["profile"] != "" {
+				names = append(names, attrs["profile"])
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+func keyringAttributes(profile string) map[string]string {
+	return map[string]string{"application": keyringApplication, "profile": profile}
+}
+
+// secretService is an open session with the Secret Service. Secrets are
+// transferred unencrypted over the session bus, which only the user can
+// connect to.
+type secretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+func withSecretService(f func(*secretService) error) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("connecting to the session bus: %w", err)
+	}
+	defer conn.Close()
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return fmt.Errorf("opening a Secret Service session: %w", err)
+	}
+	defer conn.Object(secretServiceName, session).Call("org.freedesktop.Secret.Session.Close", 0)
+
+	if err := f(&secretService{conn: conn, session: session}); err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	return nil
+}
+
+// search returns the items matching attrs, unlocking them if needed.
+func (s *secretService) search(attrs map[string]string) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".SearchItems", 0, attrs).Store(&unlocked, &locked)
+	if err != nil {
+		return nil, err
+	}
+	if len(locked) > 0 {
+		if err := s.unlock(locked); err != nil {
+			return nil, err
+		}
+	}
+	return append(unlocked, locked...), nil
+}
+
+func (s *secretService) unlock(objects []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := s.conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".Unlock", 0, objects).Store(&unlocked, &prompt)
+	if err != nil {
+		return err
+	}
+	return s.prompt(prompt)
+}
+
+// prompt shows a Secret Service prompt, such as a request for the keyring
+// password, and waits for the user to complete it. "/" means no prompt is
+// needed.
+func (s *secretService) prompt(path dbus.ObjectPath) error {
+	if path == "/" || path == "" {
+		return nil
+	}
+	err := s.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretPromptIface),
+		dbus.WithMatchMember("Completed"),
+	)
+	if err != nil {
+		return err
+	}
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.conn.Object(secretServiceName, path).Call(secretPromptIface+".Prompt", 0, "").Err; err != nil {
+		return err
+	}
+	for sig := range signals {
+		if sig.Path != path || sig.Name != secretPromptIface+".Completed" {
+			continue
+		}
+		if dismissed, _ := sig.Body[0].(bool); dismissed {
+			return errors.New("the keyring prompt was dismissed")
+		}
+		return nil
+	}
+	return errors.New("lost the connection to the keyring")
+}
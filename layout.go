@@ -0,0 +1,79 @@
+package sptui
+
+const (
+	defaultWidth  = 48
+	defaultHeight = 30
+
+	// Below either of these the UI switches to compact mode, which drops
+	// padding, abbreviates tab names and hides the help line.
+	compactWidth  = 40
+	compactHeight = 20
+
+	minListWidth  = 10
+	minListHeight = 3
+
+	itemPadding    = 2
+	tabRowHeight   = 3
+	progressHeight = 3
+)
+
+var defaultLayout = newLayout(defaultWidth, defaultHeight)
+
+// layout holds the sizes derived from the terminal size.
+type layout struct {
+	width   int
+	height  int
+	compact bool
+
+	docPadY, docPadX int
+	winPadY, winPadX int
+	helpHeight       int
+
+	// listWidth is the width of an item's text, listHeight the number of
+	// lines available to a list including its title and pagination.
+	listWidth  int
+	listHeight int
+}
+
+func newLayout(width, height int) layout {
+	l := layout{
+		width:   width,
+		height:  height,
+		compact: width < compactWidth || height < compactHeight,
+	}
+	if l.compact {
+		l.winPadX = 1
+	} else {
+		l.docPadY, l.docPadX = 1, 2
+		l.winPadY, l.winPadX = 1, 5
+		l.helpHeight = 1
+	}
+
+	l.listWidth = max(width-2*l.docPadX-2-2*l.winPadX-itemPadding, minListWidth)
+	// The tab window has no top border; the line above the list is blank.
+	l.listHeight = max(height-2*l.docPadY-tabRowHeight-2*l.winPadY-1-1-
+		progressHeight-l.helpHeight, minListHeight)
+	return l
+}
+
+// withHelp makes room for a help view of h lines.
+func (l layout) withHelp(h int) layout {
+	if l.compact {
+		return l
+	}
+	l.listHeight = max(l.listHeight+l.helpHeight-h, minListHeight)
+	l.helpHeight = h
+	return l
+}
+
+// windowWidth is the width of the tab and track windows inside their
+// borders.
+func (l layout) windowWidth() int {
+	return l.listWidth + itemPadding + 2*l.winPadX
+}
+
+// barWidth is the width of the progress bar, which lines up with the
+// outside of the window borders.
+func (l layout) barWidth() int {
+	return l.windowWidth() + 2
+}
@@ -4,22 +4,48 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/mattn/go-runewidth"
+	"github.com/zmb3/spotify/v2"
 )
 
-const (
-	listWidth  = 30
-	listHeight = 16
-)
-
-type item string
+// item is a row of a list. Lists of tracks and episodes fill in the fields
+// after title and show them as columns.
+type item struct {
+	title    string
+	artist   string
+	album    string
+	duration time.Duration
+	explicit bool
+	// addedAt is when a track was added to a playlist, or when an episode
+	// was released.
+	addedAt time.Time
+	// resume is where an episode was left off and played whether it was
+	// listened to the end.
+	resume time.Duration
+	played bool
+	// uri identifies a library entry, so that it stays selected when the
+	// list is refreshed.
+	uri spotify.URI
+
+	// index is the position of the item in the unfiltered list.
+	index int
+}
 
-func (i item) FilterValue() string { return "" }
+func (i item) FilterValue() string {
+	return CleanString(i.title) + " " + CleanString(i.artist) + " " + CleanString(i.album)
+}
 
-type itemDelegate struct{}
+type itemDelegate struct {
+	styles  Styles
+	columns []column
+}
 
 func (d itemDelegate) Height() int                             { return 1 }
 func (d itemDelegate) Spacing() int                            { return 0 }
@@ -30,25 +56,86 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		return
 	}
 
-	str := CleanString(string(i))
-
-	var fn func(strs ...string) string
-	if index == m.Index() {
-		fn = func(s ...string) string {
-			return selectedItemStyle.Render(WrapText("> "+strings.Join(s, " "), listWidth, 2))
+	selected := index == m.Index()
+	style, prefix := d.styles.item, ""
+	if selected {
+		style, prefix = d.styles.selectedItem, "> "
+	}
+	matches := m.MatchesForItem(index)
+
+	// The padding is written by hand so that the highlighted runes can be
+	// styled separately from the rest of the row.
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", style.GetPaddingLeft()))
+	style = style.Copy().UnsetPadding()
+
+	if len(d.columns) > 0 {
+		cells, spans := i.cells(d.columns, m.Width()-2)
+		if !selected {
+			prefix = "  "
 		}
-	} else {
-		fn = func(s ...string) string {
-			return itemStyle.Render(PadOrTruncate(strings.Join(s, " "), listWidth))
+		b.WriteString(style.Render(prefix))
+		for n, c := range cells {
+			if n > 0 {
+				b.WriteString(style.Render(strings.Repeat(" ", columnGap)))
+			}
+			sp := spans[n]
+			b.WriteString(highlight(c, matchesIn(matches, sp.start, sp.end-sp.start), style))
+		}
+		fmt.Fprint(w, b.String())
+		return
+	}
+
+	str := CleanString(i.title)
+
+	// Filtered rows stay on one line so that the matches line up.
+	if selected && m.FilterState() == list.Unfiltered {
+		fmt.Fprint(w, d.styles.selectedItem.Render(WrapText(prefix+str, m.Width(), 2)))
+		return
+	}
+	str = PadOrTruncate(prefix+str, m.Width())
+	b.WriteString(highlight(str, matchesIn(matches, -len([]rune(prefix)), len([]rune(str))), style))
+	fmt.Fprint(w, b.String())
+}
+
+// highlight renders s with style, underlining the runes at the given
+// indices.
+func highlight(s string, matches []int, style lipgloss.Style) string {
+	if len(matches) == 0 {
+		return style.Render(s)
+	}
+	return lipgloss.StyleRunes(s, matches, style.Copy().Underline(true), style)
+}
+
+// matchesIn returns the indices in matches that fall into the n runes
+// starting at off, relative to off.
+func matchesIn(matches []int, off, n int) []int {
+	var in []int
+	for _, i := range matches {
+		if i >= off && i < off+n {
+			in = append(in, i-off)
 		}
 	}
+	return in
+}
 
-	fmt.Fprint(w, fn(str))
+// numberItems records the position of every item so that the selection can
+// be mapped back to it while the list is filtered.
+func numberItems(items []list.Item) []list.Item {
+	for n, li := range items {
+		if i, ok := li.(item); ok {
+			i.index = n
+			items[n] = i
+		}
+	}
+	return items
 }
 
 type ListModel struct {
 	list     list.Model
+	delegate itemDelegate
 	choice   string
+	title    string
 	Fetching bool
 }
 
@@ -56,24 +143,66 @@ func (m ListModel) InitList() tea.Cmd {
 	return nil
 }
 
-func (m ListModel) UpdateList(msg tea.Msg, depth int) (ListModel, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.list.SetWidth(msg.Width)
-		return m, nil
+// Index returns the position of the selected item in the unfiltered list,
+// or -1 if the filter matches nothing.
+func (m ListModel) Index() int {
+	i, ok := m.list.SelectedItem().(item)
+	if !ok {
+		return -1
+	}
+	return i.index
+}
+
+// Filtering reports whether keys should go to the list's filter rather
+// than being treated as commands.
+func (m ListModel) Filtering(msg tea.KeyMsg, keys KeyMap) bool {
+	return m.list.SettingFilter() ||
+		(m.list.IsFiltered() && key.Matches(msg, keys.Back))
+}
 
+// SetItems replaces the items, keeping the selection and any filter.
+func (m *ListModel) SetItems(items []list.Item) tea.Cmd {
+	return m.list.SetItems(numberItems(items))
+}
+
+// refresh replaces the items with an updated copy, keeping the selection on
+// the same entry rather than at the same position.
+func (m *ListModel) refresh(items []list.Item) tea.Cmd {
+	prev, _ := m.list.SelectedItem().(item)
+	cmd := m.SetItems(items)
+	if prev.uri == "" || m.list.IsFiltered() {
+		return cmd
+	}
+	for i, it := range m.list.Items() {
+		if it.(item).uri == prev.uri {
+			m.list.Select(i)
+			break
+		}
+	}
+	return cmd
+}
+
+func (m ListModel) UpdateList(msg tea.Msg, keys KeyMap) (ListModel, tea.Cmd) {
+	m.list.KeyMap.CursorUp = keys.Up
+	m.list.KeyMap.CursorDown = keys.Down
+	m.list.KeyMap.Filter = keys.Filter
+
+	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch keypress := msg.String(); keypress {
-		case "ctrl+c", "q":
+		if m.Filtering(msg, keys) {
+			break
+		}
+		switch {
+		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
 
-		case "esc":
+		case key.Matches(msg, keys.Back):
 			return m, UpdateDepthCmd(-1)
 
-		case "enter":
+		case key.Matches(msg, keys.Select):
 			i, ok := m.list.SelectedItem().(item)
 			if ok {
-				m.choice = string(i)
+				m.choice = i.title
 			}
 
 			return m, UpdateDepthCmd(1)
@@ -86,7 +215,9 @@ func (m ListModel) UpdateList(msg tea.Msg, depth int) (ListModel, tea.Cmd) {
 	m.list, cmd = m.list.Update(msg)
 	cmds = append(cmds, cmd)
 
-	if len(m.list.Items())-m.list.Index() < 5 {
+	// Measured in the unfiltered list, which is what a page adds to, so that
+	// the last matches of a filter load the next page too.
+	if i := m.Index(); i >= 0 && len(m.list.Items())-i < 5 {
 		cmds = append(cmds, LoadMoreCmd())
 	}
 
@@ -108,23 +239,53 @@ func LoadMoreCmd() tea.Cmd {
 	}
 }
 
+// SetSize sets the width of the item text and the height of the whole list.
+func (m *ListModel) SetSize(width, height int) {
+	m.list.SetSize(width, height)
+	if m.title != "" {
+		m.setTitle(m.title)
+	}
+}
+
+func (m *ListModel) setTitle(title string) {
+	m.title = title
+	width := m.list.Width()
+	if runewidth.StringWidth(title) <= width {
+		m.list.Title = title
+	} else {
+		m.list.Title = WrapText(title, width, 10)
+	}
+}
+
 func (m ListModel) View(depth int) string {
 	return "\n" + m.list.View()
 }
 
 func NewListModel(items []list.Item, opts ...ListModelOpt) ListModel {
 
-	l := list.New(items, itemDelegate{}, listWidth, listHeight)
+	l := list.New(numberItems(items), itemDelegate{styles: defaultStyles}, defaultLayout.listWidth, defaultLayout.listHeight)
 
 	l.SetShowTitle(false)
 	l.SetShowStatusBar(false)
-	l.SetFilteringEnabled(false)
-	l.Styles.PaginationStyle = paginationStyle
-	l.Styles.HelpStyle = helpStyle
+	l.SetFilteringEnabled(true)
+	l.Filter = list.UnsortedFilter
+	// Only the active list receives messages, so a blinking cursor would
+	// stop blinking when switching tabs.
+	l.FilterInput.Cursor.SetMode(cursor.CursorStatic)
+	l.Styles = defaultStyles.list
 	l.DisableQuitKeybindings()
+	// Leave b/u/f/d free for player controls.
+	l.KeyMap.PrevPage = key.NewBinding(
+		key.WithKeys("left", "h", "pgup"),
+		key.WithHelp("←/h/pgup", "prev page"),
+	)
+	l.KeyMap.NextPage = key.NewBinding(
+		key.WithKeys("right", "l", "pgdown"),
+		key.WithHelp("→/l/pgdn", "next page"),
+	)
 	l.SetShowHelp(false)
 
-	m := ListModel{list: l}
+	m := ListModel{list: l, delegate: itemDelegate{styles: defaultStyles}}
 
 	for _, opt := range opts {
 		opt(&m)
@@ -134,14 +295,35 @@ func NewListModel(items []list.Item, opts ...ListModelOpt) ListModel {
 
 type ListModelOpt func(*ListModel)
 
+func WithStyles(s Styles) ListModelOpt {
+	return func(m *ListModel) {
+		m.delegate.styles = s
+		m.list.SetDelegate(m.delegate)
+		m.list.Styles = s.list
+		m.list.FilterInput.PromptStyle = s.list.FilterPrompt
+		m.list.FilterInput.Cursor.Style = s.list.FilterCursor
+	}
+}
+
 func WithTitle(title string) ListModelOpt {
 	return func(m *ListModel) {
 		m.list.SetShowTitle(true)
-		title = CleanString(title)
-		if runewidth.StringWidth(title) <= listWidth {
-			m.list.Title = title
-		} else {
-			m.list.Title = WrapText(title, listWidth, 10)
-		}
+		m.setTitle(CleanString(title))
+	}
+}
+
+// WithColumns shows items as a table of cols, dropping columns that do not
+// fit.
+func WithColumns(cols ...column) ListModelOpt {
+	return func(m *ListModel) {
+		m.delegate.columns = cols
+		m.list.SetDelegate(m.delegate)
+	}
+}
+
+// WithSize must come before WithTitle so that the title wraps at width.
+func WithSize(width, height int) ListModelOpt {
+	return func(m *ListModel) {
+		m.SetSize(width, height)
 	}
 }
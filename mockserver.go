@@ -0,0 +1,241 @@
+package sptui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	mockAccessToken  = "mock-access-token"
+	mockRefreshToken = "mock-refresh-token"
+	mockAuthCode     = "mock-auth-code"
+)
+
+// MockServer serves the parts of the Spotify Web API and Accounts service
+// that sptui uses, backed by a FakeBackend. Point SPOTIFY_API_URL at
+// <addr>/v1/ and SPOTIFY_ACCOUNTS_URL at <addr> to run the app against it.
+// Any bearer token is accepted and authorization is granted without a prompt.
+type MockServer struct {
+	backend *FakeBackend
+	mux     *http.ServeMux
+}
+
+func NewMockServer(backend *FakeBackend) *MockServer {
+	s := &MockServer{
+		backend: backend,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /authorize", s.authorize)
+	s.mux.HandleFunc("POST /api/token", s.token)
+
+	s.handle("GET /v1/me/albums", func(r *http.Request) (any, error) {
+		return s.backend.CurrentUsersAlbums(r.Context(), queryInt(r, "offset"))
+	})
+	s.handle("GET /v1/me/playlists", func(r *http.Request) (any, error) {
+		return s.backend.CurrentUsersPlaylists(r.Context(), queryInt(r, "offset"))
+	})
+	s.handle("GET /v1/me/shows", func(r *http.Request) (any, error) {
+		return s.backend.CurrentUsersShows(r.Context(), queryInt(r, "offset"))
+	})
+	s.handle("GET /v1/albums/{id}", func(r *http.Request) (any, error) {
+		return s.backend.GetAlbum(r.Context(), spotify.ID(r.PathValue("id")))
+	})
+	s.handle("GET /v1/albums/{id}/tracks", func(r *http.Request) (any, error) {
+		return s.backend.GetAlbumTracks(r.Context(), spotify.ID(r.PathValue("id")), queryInt(r, "offset"))
+	})
+	s.handle("GET /v1/playlists/{id}", func(r *http.Request) (any, error) {
+		return s.backend.GetPlaylist(r.Context(), spotify.ID(r.PathValue("id")))
+	})
+	s.handle("GET /v1/playlists/{id}/tracks", func(r *http.Request) (any, error) {
+		return s.backend.GetPlaylistTracks(r.Context(), spotify.ID(r.PathValue("id")), queryInt(r, "offset"))
+	})
+	s.handle("GET /v1/shows/{id}", func(r *http.Request) (any, error) {
+		return s.backend.GetShow(r.Context(), spotify.ID(r.PathValue("id")))
+	})
+	s.handle("GET /v1/shows/{id}/episodes", func(r *http.Request) (any, error) {
+		return s.backend.GetShowEpisodes(r.Context(), spotify.ID(r.PathValue("id")), queryInt(r, "offset"))
+	})
+	s.handle("GET /v1/episodes/{id}", func(r *http.Request) (any, error) {
+		return s.backend.GetEpisode(r.Context(), spotify.ID(r.PathValue("id")))
+	})
+	s.handle("GET /v1/search", func(r *http.Request) (any, error) {
+		return s.backend.Search(r.Context(), r.URL.Query().Get("q"), queryInt(r, "offset"))
+	})
+
+	s.handle("GET /v1/me/player", func(r *http.Request) (any, error) {
+		state, err := s.backend.PlayerState(r.Context())
+		if state == nil {
+			return nil, err
+		}
+		return state, err
+	})
+	s.handle("PUT /v1/me/player", func(r *http.Request) (any, error) {
+		var body struct {
+			DeviceIDs []spotify.ID `json:"device_ids"`
+			Play      bool         `json:"play"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.DeviceIDs) != 1 {
+			return nil, spotify.Error{Status: http.StatusBadRequest, Message: "Malformed json"}
+		}
+		return nil, s.backend.TransferPlayback(r.Context(), body.DeviceIDs[0], body.Play)
+	})
+	s.handle("GET /v1/me/player/currently-playing", func(r *http.Request) (any, error) {
+		cp, err := s.backend.PlayerCurrentlyPlaying(r.Context())
+		if err != nil || (cp.Item == nil && !cp.Playing) {
+			return nil, err
+		}
+		return cp, nil
+	})
+	s.handle("GET /v1/me/player/devices", func(r *http.Request) (any, error) {
+		devices, err := s.backend.PlayerDevices(r.Context())
+		return map[string]any{"devices": devices}, err
+	})
+	s.handle("PUT /v1/me/player/play", func(r *http.Request) (any, error) {
+		var opt spotify.PlayOptions
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+				return nil, spotify.Error{Status: http.StatusBadRequest, Message: "Malformed json"}
+			}
+		}
+		if id := r.URL.Query().Get("device_id"); id != "" {
+			deviceID := spotify.ID(id)
+			opt.DeviceID = &deviceID
+		}
+		return nil, s.backend.PlayOpt(r.Context(), &opt)
+	})
+	s.handle("PUT /v1/me/player/pause", func(r *http.Request) (any, error) {
+		return nil, s.backend.Pause(r.Context())
+	})
+	s.handle("POST /v1/me/player/next", func(r *http.Request) (any, error) {
+		return nil, s.backend.Next(r.Context())
+	})
+	s.handle("POST /v1/me/player/previous", func(r *http.Request) (any, error) {
+		return nil, s.backend.Previous(r.Context())
+	})
+	s.handle("PUT /v1/me/player/seek", func(r *http.Request) (any, error) {
+		position, err := strconv.Atoi(r.URL.Query().Get("position_ms"))
+		if err != nil {
+			return nil, spotify.Error{Status: http.StatusBadRequest, Message: "Missing required field: position_ms"}
+		}
+		return nil, s.backend.Seek(r.Context(), position)
+	})
+	s.handle("PUT /v1/me/player/volume", func(r *http.Request) (any, error) {
+		percent, err := strconv.Atoi(r.URL.Query().Get("volume_percent"))
+		if err != nil {
+			return nil, spotify.Error{Status: http.StatusBadRequest, Message: "Missing required field: volume_percent"}
+		}
+		return nil, s.backend.Volume(r.Context(), percent)
+	})
+	s.handle("PUT /v1/me/player/shuffle", func(r *http.Request) (any, error) {
+		shuffle, err := strconv.ParseBool(r.URL.Query().Get("state"))
+		if err != nil {
+			return nil, spotify.Error{Status: http.StatusBadRequest, Message: "Invalid state"}
+		}
+		return nil, s.backend.Shuffle(r.Context(), shuffle)
+	})
+	s.handle("PUT /v1/me/player/repeat", func(r *http.Request) (any, error) {
+		return nil, s.backend.Repeat(r.Context(), r.URL.Query().Get("state"))
+	})
+	s.handle("GET /v1/me/player/queue", func(r *http.Request) (any, error) {
+		return s.backend.GetQueue(r.Context())
+	})
+	s.handle("POST /v1/me/player/queue", func(r *http.Request) (any, error) {
+		return nil, s.backend.QueueItem(r.Context(), spotify.URI(r.URL.Query().Get("uri")))
+	})
+
+	return s
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle registers an authenticated Web API endpoint. A nil result is
+// written as 204 No Content.
+func (s *MockServer) handle(pattern string, fn func(r *http.Request) (any, error)) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeMockError(w, spotify.Error{Status: http.StatusUnauthorized, Message: "Invalid access token"})
+			return
+		}
+
+		result, err := fn(r)
+		if err != nil {
+			writeMockError(w, err)
+			return
+		}
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// authorize approves every request and redirects straight back to the app.
+func (s *MockServer) authorize(w http.ResponseWriter, r *http.Request) {
+	redirect, err := url.Parse(r.URL.Query().Get("redirect_uri"))
+	if err != nil || redirect.String() == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	q := redirect.Query()
+	q.Set("code", mockAuthCode)
+	q.Set("state", r.URL.Query().Get("state"))
+	redirect.RawQuery = q.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *MockServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		ok = r.PostForm.Get("code") == mockAuthCode
+	case "refresh_token":
+		ok = r.PostForm.Get("refresh_token") != ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "Invalid authorization code",
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token":  mockAccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+		"refresh_token": mockRefreshToken,
+		"scope":         strings.Join(scopes, " "),
+	})
+}
+
+func writeMockError(w http.ResponseWriter, err error) {
+	var apiErr spotify.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = spotify.Error{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]spotify.Error{"error": apiErr})
+}
+
+func queryInt(r *http.Request, key string) int {
+	n, _ := strconv.Atoi(r.URL.Query().Get(key))
+	return n
+}
@@ -0,0 +1,401 @@
+package sptui
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/zmb3/spotify/v2"
+)
+
+// The MPRIS D-Bus interface. See
+// https://specifications.freedesktop.org/mpris-spec/latest/.
+const (
+	mprisName        = "org.mpris.MediaPlayer2.sptui"
+	mprisPath        = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	mprisRootIface   = "org.mpris.MediaPlayer2"
+	mprisPlayerIface = "org.mpris.MediaPlayer2.Player"
+	propertiesIface  = "org.freedesktop.DBus.Properties"
+
+	mprisNoTrack   = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+	mprisTrackPath = "/io/github/szktkfm/sptui/"
+
+	// seekTolerance is how far the position may drift from where it is
+	// expected before it counts as a seek.
+	seekTolerance = 2 * time.Second
+)
+
+// MPRIS exposes the TUI's player on the session bus, so that desktop media
+// keys and tools such as playerctl can see and control it. Register it with
+// WithPlayerObserver and call Start once the tea.Program exists.
+type MPRIS struct {
+	mu   sync.Mutex
+	conn *dbus.Conn
+	send func(tea.Msg)
+	np   NowPlaying
+	// updated is when np was received, to advance its position.
+	updated time.Time
+}
+
+func NewMPRIS() *MPRIS {
+	return &MPRIS{}
+}
+
+// Start serves the player on conn, passing requests to send, which is
+// usually tea.Program.Send. If another sptui already owns the bus name, an
+// instance name is used as the specification suggests.
+func (m *MPRIS) Start(conn *dbus.Conn, send func(tea.Msg)) error {
+	m.mu.Lock()
+	m.send = send
+	m.mu.Unlock()
+
+	exports := map[string]any{
+		mprisRootIface:                        mprisRoot{m},
+		mprisPlayerIface:                      mprisPlayer{m},
+		propertiesIface:                       mprisProperties{m},
+		"org.freedesktop.DBus.Introspectable": introspect.Introspectable(mprisIntrospection),
+	}
+	for iface, v := range exports {
+		// Seek is exported as SeekBy, which go vet does not mistake for
+		// io.Seeker.
+		if err := conn.ExportWithMap(v, map[string]string{"SeekBy": "Seek"}, mprisPath, iface); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{mprisName, fmt.Sprintf("%s.instance%d", mprisName, os.Getpid())} {
+		reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+		if err != nil {
+			return err
+		}
+		if reply == dbus.RequestNameReplyPrimaryOwner {
+			// Changes are only signalled once the player can be found.
+			m.mu.Lock()
+			m.conn = conn
+			m.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("the bus name %s is taken", mprisName)
+}
+
+// PlayerChanged updates the properties and signals the changes.
+func (m *MPRIS) PlayerChanged(np NowPlaying) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := m.playerProperties()
+	expected := m.np.after(time.Since(m.updated))
+	m.np, m.updated = np, time.Now()
+	if m.conn == nil {
+		return
+	}
+
+	changed := map[string]dbus.Variant{}
+	for name, v := range m.playerProperties() {
+		// Position changes all the time and is never signalled.
+		if name != "Position" && !reflect.DeepEqual(v.Value(), old[name].Value()) {
+			changed[name] = v
+		}
+	}
+	if len(changed) > 0 {
+		m.conn.Emit(mprisPath, propertiesIface+".PropertiesChanged", mprisPlayerIface, changed, []string{})
+	}
+
+	drift := time.Duration(np.PositionMs-expected.PositionMs) * time.Millisecond
+	if np.URI != "" && np.URI == expected.URI && (drift > seekTolerance || drift < -seekTolerance) {
+		m.conn.Emit(mprisPath, mprisPlayerIface+".Seeked", microseconds(np.PositionMs))
+	}
+}
+
+// control passes msg on to the TUI.
+func (m *MPRIS) control(msg ControlMsg) *dbus.Error {
+	m.mu.Lock()
+	send := m.send
+	m.mu.Unlock()
+	send(msg)
+	return nil
+}
+
+func (m *MPRIS) rootProperties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"CanQuit":             dbus.MakeVariant(true),
+		"CanRaise":            dbus.MakeVariant(false),
+		"HasTrackList":        dbus.MakeVariant(false),
+		"Identity":            dbus.MakeVariant("sptui"),
+		"SupportedUriSchemes": dbus.MakeVariant([]string{"spotify"}),
+		"SupportedMimeTypes":  dbus.MakeVariant([]string{}),
+	}
+}
+
+// playerProperties must be called with m.mu held.
+func (m *MPRIS) playerProperties() map[string]dbus.Variant {
+	np := m.np.after(time.Since(m.updated))
+	active := np.Device != ""
+	loaded := active && np.Type != ""
+
+	return map[string]dbus.Variant{
+		"PlaybackStatus": dbus.MakeVariant(strings.ToUpper(np.State()[:1]) + np.State()[1:]),
+		"LoopStatus":     dbus.MakeVariant(loopStatus(np.Repeat)),
+		"Rate":           dbus.MakeVariant(1.0),
+		"MinimumRate":    dbus.MakeVariant(1.0),
+		"MaximumRate":    dbus.MakeVariant(1.0),
+		"Shuffle":        dbus.MakeVariant(np.Shuffle),
+		"Metadata":       dbus.MakeVariant(mprisMetadata(np)),
+		"Volume":         dbus.MakeVariant(float64(np.Volume) / 100),
+		"Position":       dbus.MakeVariant(microseconds(np.PositionMs)),
+		"CanGoNext":      dbus.MakeVariant(loaded),
+		"CanGoPrevious":  dbus.MakeVariant(loaded),
+		"CanPlay":        dbus.MakeVariant(loaded),
+		"CanPause":       dbus.MakeVariant(loaded),
+		"CanSeek":        dbus.MakeVariant(loaded),
+		"CanControl":     dbus.MakeVariant(true),
+	}
+}
+
+func mprisMetadata(np NowPlaying) map[string]dbus.Variant {
+	md := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(mprisTrackID(np.URI)),
+	}
+	if np.Type == "" {
+		return md
+	}
+	md["mpris:length"] = dbus.MakeVariant(microseconds(np.DurationMs))
+	md["xesam:title"] = dbus.MakeVariant(np.Title)
+	if np.Artist != "" {
+		md["xesam:artist"] = dbus.MakeVariant(strings.Split(np.Artist, ", "))
+	}
+	if np.Album != "" {
+		md["xesam:album"] = dbus.MakeVariant(np.Album)
+	}
+	if np.ArtURL != "" {
+		md["mpris:artUrl"] = dbus.MakeVariant(np.ArtURL)
+	}
+	if kind, id, ok := strings.Cut(strings.TrimPrefix(np.URI, "spotify:"), ":"); ok {
+		md["xesam:url"] = dbus.MakeVariant("https://open.spotify.com/" + kind + "/" + id)
+	}
+	return md
+}
+
+// mprisTrackID turns a Spotify URI into an object path, as MPRIS identifies
+// tracks by those.
+func mprisTrackID(uri string) dbus.ObjectPath {
+	if uri == "" {
+		return mprisNoTrack
+	}
+	path := dbus.ObjectPath(mprisTrackPath + strings.ReplaceAll(strings.TrimPrefix(uri, "spotify:"), ":", "/"))
+	if !path.IsValid() {
+		return mprisNoTrack
+	}
+	return path
+}
+
+func loopStatus(repeat string) string {
+	switch repeat {
+	case "track":
+		return "Track"
+	case "context":
+		return "Playlist"
+	}
+	return "None"
+}
+
+func microseconds(ms int) int64 {
+	return int64(ms) * 1000
+}
+
+// mprisRoot is the org.mpris.MediaPlayer2 interface.
+type mprisRoot struct{ m *MPRIS }
+
+func (r mprisRoot) Raise() *dbus.Error { return nil }
+
+func (r mprisRoot) Quit() *dbus.Error {
+	r.m.mu.Lock()
+	send := r.m.send
+	r.m.mu.Unlock()
+	send(tea.QuitMsg{})
+	return nil
+}
+
+// mprisPlayer is the org.mpris.MediaPlayer2.Player interface.
+type mprisPlayer struct{ m *MPRIS }
+
+func (p mprisPlayer) Next() *dbus.Error     { return p.m.control(ControlMsg{Action: ControlNext}) }
+func (p mprisPlayer) Previous() *dbus.Error { return p.m.control(ControlMsg{Action: ControlPrevious}) }
+func (p mprisPlayer) Pause() *dbus.Error    { return p.m.control(ControlMsg{Action: ControlPause}) }
+func (p mprisPlayer) Play() *dbus.Error     { return p.m.control(ControlMsg{Action: ControlPlay}) }
+
+func (p mprisPlayer) PlayPause() *dbus.Error {
+	return p.m.control(ControlMsg{Action: ControlPlayPause})
+}
+
+// Stop pauses, as Spotify has no notion of stopping.
+func (p mprisPlayer) Stop() *dbus.Error {
+	return p.m.control(ControlMsg{Action: ControlPause})
+}
+
+func (p mprisPlayer) SeekBy(offset int64) *dbus.Error {
+	return p.m.control(ControlMsg{
+		Action:   ControlSeek,
+		Position: time.Duration(offset) * time.Microsecond,
+		Relative: true,
+	})
+}
+
+// SetPosition is ignored unless track is still playing, as the
+// specification requires.
+func (p mprisPlayer) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	p.m.mu.Lock()
+	np := p.m.np
+	p.m.mu.Unlock()
+	if track != mprisTrackID(np.URI) || position < 0 || position > microseconds(np.DurationMs) {
+		return nil
+	}
+	return p.m.control(ControlMsg{Action: ControlSeek, Position: time.Duration(position) * time.Microsecond})
+}
+
+func (p mprisPlayer) OpenUri(uri string) *dbus.Error {
+	if _, err := PlayURIOptions(spotify.URI(uri)); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return p.m.control(ControlMsg{Action: ControlPlayURI, URI: spotify.URI(uri)})
+}
+
+// mprisProperties is the org.freedesktop.DBus.Properties interface. It is
+// implemented by hand so that Position can be computed when it is read.
+type mprisProperties struct{ m *MPRIS }
+
+func (p mprisProperties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	switch iface {
+	case mprisRootIface:
+		return p.m.rootProperties(), nil
+	case mprisPlayerIface:
+		return p.m.playerProperties(), nil
+	}
+	return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownInterface", Body: []any{iface}}
+}
+
+func (p mprisProperties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	props, err := p.GetAll(iface)
+	if err != nil {
+		return dbus.Variant{}, err
+	}
+	v, ok := props[name]
+	if !ok {
+		return dbus.Variant{}, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownProperty", Body: []any{name}}
+	}
+	return v, nil
+}
+
+func (p mprisProperties) Set(iface, name string, value dbus.Variant) *dbus.Error {
+	invalid := &dbus.Error{Name: "org.freedesktop.DBus.Error.InvalidArgs", Body: []any{name}}
+	if iface != mprisPlayerIface {
+		return &dbus.Error{Name: "org.freedesktop.DBus.Error.PropertyReadOnly", Body: []any{name}}
+	}
+
+	switch name {
+	case "Volume":
+		v, ok := value.Value().(float64)
+		if !ok {
+			return invalid
+		}
+		return p.m.control(ControlMsg{Action: ControlVolume, Volume: int(math.Round(v * 100))})
+	case "Shuffle":
+		v, ok := value.Value().(bool)
+		if !ok {
+			return invalid
+		}
+		return p.m.control(ControlMsg{Action: ControlShuffle, Shuffle: v})
+	case "LoopStatus":
+		repeat := map[string]string{"None": "off", "Playlist": "context", "Track": "track"}
+		v, _ := value.Value().(string)
+		if repeat[v] == "" {
+			return invalid
+		}
+		return p.m.control(ControlMsg{Action: ControlRepeat, Repeat: repeat[v]})
+	case "Rate":
+		// Playback speed cannot be changed, so anything but 1 is ignored.
+		return nil
+	}
+	return &dbus.Error{Name: "org.freedesktop.DBus.Error.PropertyReadOnly", Body: []any{name}}
+}
+
+const mprisIntrospection = `<node>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek">
+      <arg name="Offset" type="x" direction="in"/>
+    </method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri">
+      <arg name="Uri" type="s" direction="in"/>
+    </method>
+    <signal name="Seeked">
+      <arg name="Position" type="x"/>
+    </signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="LoopStatus" type="s" access="readwrite"/>
+    <property name="Rate" type="d" access="readwrite"/>
+    <property name="Shuffle" type="b" access="readwrite"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="readwrite"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface" type="s"/>
+      <arg name="changed_properties" type="a{sv}"/>
+      <arg name="invalidated_properties" type="as"/>
+    </signal>
+  </interface>
+` + introspect.IntrospectDataString + `</node>`
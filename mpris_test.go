@@ -0,0 +1,119 @@
+package sptui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/godbus/dbus/v5"
+)
+
+// newTestMPRIS returns an MPRIS without a bus whose control messages are
+// collected in sent.
+func newTestMPRIS(np NowPlaying) (m *MPRIS, sent *[]tea.Msg) {
+	sent = &[]tea.Msg{}
+	m = NewMPRIS()
+	m.send = func(msg tea.Msg) { *sent = append(*sent, msg) }
+	m.np, m.updated = np, time.Now()
+	return m, sent
+}
+
+func TestMPRISSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  tea.Msg
+		err   bool
+	}{
+		{"Volume", 0.42, ControlMsg{Action: ControlVolume, Volume: 42}, false},
+		{"Volume", "loud", nil, true},
+		{"Shuffle", true, ControlMsg{Action: ControlShuffle, Shuffle: true}, false},
+		{"LoopStatus", "Playlist", ControlMsg{Action: ControlRepeat, Repeat: "context"}, false},
+		{"LoopStatus", "Track", ControlMsg{Action: ControlRepeat, Repeat: "track"}, false},
+		{"LoopStatus", "None", ControlMsg{Action: ControlRepeat, Repeat: "off"}, false},
+		{"LoopStatus", "Forever", nil, true},
+		{"Rate", 2.0, nil, false},
+		{"PlaybackStatus", "Playing", nil, true},
+	}
+	for _, tt := range tests {
+		m, sent := newTestMPRIS(NowPlaying{})
+		err := mprisProperties{m}.Set(mprisPlayerIface, tt.name, dbus.MakeVariant(tt.value))
+		if (err != nil) != tt.err {
+			t.Errorf("Set(%s, %v) returned %v", tt.name, tt.value, err)
+		}
+		var want []tea.Msg
+		if tt.want != nil {
+			want = []tea.Msg{tt.want}
+		}
+		if len(*sent) != len(want) || len(want) > 0 && !reflect.DeepEqual((*sent)[0], want[0]) {
+			t.Errorf("Set(%s, %v) sent %v, want %v", tt.name, tt.value, *sent, want)
+		}
+	}
+}
+
+func TestMPRISSetPosition(t *testing.T) {
+	np := NowPlaying{URI: "spotify:track:abc", Type: "track", DurationMs: 200000, Device: "Laptop"}
+	track := mprisTrackID(np.URI)
+	tests := []struct {
+		name     string
+		track    dbus.ObjectPath
+		position int64
+		want     time.Duration
+		ignored  bool
+	}{
+		{"current track", track, microseconds(90000), 90 * time.Second, false},
+		{"other track", mprisTrackID("spotify:track:xyz"), microseconds(90000), 0, true},
+		{"negative", track, -1, 0, true},
+		{"past the end", track, microseconds(200001), 0, true},
+	}
+	for _, tt := range tests {
+		m, sent := newTestMPRIS(np)
+		mprisPlayer{m}.SetPosition(tt.track, tt.position)
+		if tt.ignored {
+			if len(*sent) != 0 {
+				t.Errorf("%s: sent %v, want nothing", tt.name, *sent)
+			}
+			continue
+		}
+		want := ControlMsg{Action: ControlSeek, Position: tt.want}
+		if len(*sent) != 1 || !reflect.DeepEqual((*sent)[0], want) {
+			t.Errorf("%s: sent %v, want %v", tt.name, *sent, want)
+		}
+	}
+}
+
+func TestMPRISMetadata(t *testing.T) {
+	np := NowPlaying{
+		URI:        "spotify:track:abc",
+		Type:       "track",
+		Title:      "Polar Night",
+		Artist:     "The Aurora Band, Mira Okada",
+		Album:      "Northern Lights",
+		DurationMs: 150000,
+	}
+	md := mprisMetadata(np)
+	want := map[string]any{
+		"mpris:trackid": dbus.ObjectPath("/io/github/szktkfm/sptui/track/abc"),
+		"mpris:length":  int64(150000000),
+		"xesam:title":   "Polar Night",
+		"xesam:artist":  []string{"The Aurora Band", "Mira Okada"},
+		"xesam:album":   "Northern Lights",
+		"xesam:url":     "https://open.spotify.com/track/abc",
+	}
+	if len(md) != len(want) {
+		t.Errorf("got %d entries, want %d: %v", len(md), len(want), md)
+	}
+	for k, v := range want {
+		if got := md[k].Value(); !reflect.DeepEqual(got, v) {
+			t.Errorf("%s = %v, want %v", k, got, v)
+		}
+	}
+
+	if got := mprisMetadata(NowPlaying{})["mpris:trackid"].Value(); got != mprisNoTrack {
+		t.Errorf("trackid with nothing playing = %v, want %v", got, mprisNoTrack)
+	}
+	if got := mprisTrackID("spotify:track:not valid"); got != mprisNoTrack {
+		t.Errorf("trackid of an invalid URI = %v, want %v", got, mprisNoTrack)
+	}
+}
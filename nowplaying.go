@@ -0,0 +1,190 @@
+package sptui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// NowPlaying summarizes the player for output outside the TUI. The zero
+// value means that no device is active.
+type NowPlaying struct {
+	Playing bool `json:"playing"`
+	// Type is "track" or "episode", or empty when nothing is loaded.
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+	// Artist is the show of an episode.
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	URI        string `json:"uri,omitempty"`
+	ArtURL     string `json:"art_url,omitempty"`
+	PositionMs int    `json:"position_ms"`
+	DurationMs int    `json:"duration_ms"`
+
+	Device  string `json:"device,omitempty"`
+	Volume  int    `json:"volume"`
+	Shuffle bool   `json:"shuffle"`
+	Repeat  string `json:"repeat,omitempty"`
+}
+
+// State is "playing", "paused" or "stopped".
+func (np NowPlaying) State() string {
+	switch {
+	case np.Type == "":
+		return "stopped"
+	case np.Playing:
+		return "playing"
+	}
+	return "paused"
+}
+
+// Position is the position in the current item, such as 1:23.
+func (np NowPlaying) Position() string {
+	return clock(np.PositionMs)
+}
+
+// Duration is the length of the current item.
+func (np NowPlaying) Duration() string {
+	return clock(np.DurationMs)
+}
+
+// Progress is the position and the length of the current item, such as
+// 1:23/4:56, or empty when nothing is loaded.
+func (np NowPlaying) Progress() string {
+	if np.Type == "" {
+		return ""
+	}
+	return np.Position() + "/" + np.Duration()
+}
+
+func clock(ms int) string {
+	if ms < 1000 {
+		return "0:00"
+	}
+	return formatDuration(time.Duration(ms) * time.Millisecond)
+}
+
+// after returns np as it should be d after it was fetched.
+func (np NowPlaying) after(d time.Duration) NowPlaying {
+	if np.Playing {
+		np.PositionMs = min(np.PositionMs+int(d.Milliseconds()), np.DurationMs)
+	}
+	return np
+}
+
+// GetNowPlaying fetches the player state, and the show of the current item
+// when it is an episode.
+func GetNowPlaying(ctx context.Context, client Backend) (NowPlaying, error) {
+	return getNowPlaying(ctx, client, NowPlaying{})
+}
+
+// getNowPlaying is GetNowPlaying reusing the show from prev when the same
+// episode is still playing.
+func getNowPlaying(ctx context.Context, client Backend, prev NowPlaying) (NowPlaying, error) {
+	state, err := client.PlayerState(ctx)
+	if err != nil {
+		return NowPlaying{}, err
+	}
+	np := newNowPlaying(state, nil)
+	if np.Type != "episode" {
+		return np, nil
+	}
+	if prev.URI == np.URI {
+		np.Artist, np.ArtURL = prev.Artist, prev.ArtURL
+		return np, nil
+	}
+	episode, err := client.GetEpisode(ctx, state.Item.ID)
+	if err != nil {
+		return NowPlaying{}, err
+	}
+	return newNowPlaying(state, episode), nil
+}
+
+// newNowPlaying summarizes state. episode has the show of the current item,
+// if it is that episode.
+func newNowPlaying(state *spotify.PlayerState, episode *spotify.EpisodePage) NowPlaying {
+	if state == nil {
+		return NowPlaying{}
+	}
+	np := NowPlaying{
+		Playing:    state.Playing,
+		PositionMs: state.Progress,
+		Device:     state.Device.Name,
+		Volume:     int(state.Device.Volume),
+		Shuffle:    state.ShuffleState,
+		Repeat:     state.RepeatState,
+	}
+	item := state.Item
+	if item == nil {
+		return np
+	}
+
+	np.Type = item.Type
+	np.Title = item.Name
+	np.URI = string(item.URI)
+	np.DurationMs = item.Duration
+	if isEpisode(item) {
+		if episode != nil && episode.URI == item.URI {
+			np.Artist = episode.Show.Name
+			if len(episode.Images) > 0 {
+				np.ArtURL = episode.Images[0].URL
+			}
+		}
+		return np
+	}
+
+	var artists []string
+	for _, a := range item.Artists {
+		artists = append(artists, a.Name)
+	}
+	np.Artist = strings.Join(artists, ", ")
+	np.Album = item.Album.Name
+	if len(item.Album.Images) > 0 {
+		np.ArtURL = item.Album.Images[0].URL
+	}
+	return np
+}
+
+// WatchNowPlaying calls f with the player state every second until ctx is
+// done. The Web API is polled every interval and when the current item
+// should have ended; in between, the position is advanced locally. A failed
+// poll is passed to onErr and tried again at the next interval. Only
+// ErrTokenRevoked ends the watch early.
+func WatchNowPlaying(ctx context.Context, client Backend, interval time.Duration, f func(NowPlaying), onErr func(error)) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var np NowPlaying
+	// fetched is when np was fetched and tried when the last poll was made.
+	var fetched, tried time.Time
+	for {
+		now := time.Now()
+		ended := tried == fetched && np.Playing && np.after(now.Sub(fetched)).PositionMs >= np.DurationMs
+		if tried.IsZero() || now.Sub(tried) >= interval || ended {
+			tried = now
+			latest, err := getNowPlaying(ctx, client, np)
+			switch {
+			case ctx.Err() != nil:
+				return nil
+			case errors.Is(err, ErrTokenRevoked):
+				return err
+			case err != nil:
+				onErr(err)
+			default:
+				np, fetched = latest, now
+			}
+		}
+		if !fetched.IsZero() {
+			f(np.after(now.Sub(fetched)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
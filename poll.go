@@ -0,0 +1,101 @@
+package sptui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	// defaultPollInterval is how often the player state is fetched unless
+	// the config file says otherwise.
+	defaultPollInterval = 5 * time.Second
+	// minPollInterval keeps polling well clear of the rate limit.
+	minPollInterval = time.Second
+	// playbackDelay gives Spotify time to carry out a playback command
+	// before the new state is fetched.
+	playbackDelay = 500 * time.Millisecond
+)
+
+// PlayerConfig is the [player] section of the config file.
+type PlayerConfig struct {
+	// PollInterval is how often the player state is fetched, such as "5s",
+	// so that changes made on other devices show up. Zero means every
+	// five seconds.
+	PollInterval time.Duration `toml:"poll_interval"`
+}
+
+func (c PlayerConfig) validate() error {
+	if c.PollInterval != 0 && c.PollInterval < minPollInterval {
+		return fmt.Errorf("player: poll_interval must be at least %s", minPollInterval)
+	}
+	return nil
+}
+
+func (c PlayerConfig) pollInterval() time.Duration {
+	if c.PollInterval == 0 {
+		return defaultPollInterval
+	}
+	return c.PollInterval
+}
+
+// pollTickMsg asks for the player state to be polled. id tells the polling
+// of one login apart from that of an earlier one.
+type pollTickMsg struct {
+	id int
+}
+
+// pollStateMsg is the player state fetched by a poll.
+type pollStateMsg struct {
+	id    int
+	state *spotify.PlayerState
+	err   error
+}
+
+func pollTickCmd(id int, interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return pollTickMsg{id: id}
+	})
+}
+
+func pollPlayerStateCmd(client Backend, id int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		state, err := client.PlayerState(ctx)
+		return pollStateMsg{id: id, state: state, err: err}
+	}
+}
+
+// updatePoll fetches the player state on every tick and schedules the next
+// tick once it has arrived, so that slow responses do not pile up.
+func updatePoll(m TabModel, msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case pollTickMsg:
+		if msg.id != m.pollID {
+			return m, nil
+		}
+		return m, pollPlayerStateCmd(m.client, m.pollID)
+
+	case pollStateMsg:
+		if msg.id != m.pollID {
+			return m, nil
+		}
+		next := pollTickCmd(m.pollID, m.player.pollInterval())
+		if msg.err != nil {
+			// Polls run in the background, so a failed one is left to the
+			// next unless the login is gone.
+			if errors.Is(msg.err, ErrTokenRevoked) {
+				return m.Update(ErrMsg{Err: msg.err})
+			}
+			return m, next
+		}
+		model, cmd := m.Update(PlayerStateMsg{State: msg.state})
+		return model, tea.Batch(cmd, next)
+	}
+	return m, nil
+}
@@ -0,0 +1,104 @@
+package sptui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// DefaultProfile is the profile used without --profile. Its token stays in
+// the location used before profiles existed.
+const DefaultProfile = "default"
+
+var (
+	profilesDirPath  = ".config/sptui/profiles"
+	validProfileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// ProfileConfig is a [profiles.<name>] section of the config file.
+type ProfileConfig struct {
+	ClientID string `toml:"client_id"`
+}
+
+// Profile is a Spotify account: the app it logs in with and where its token
+// is kept.
+type Profile struct {
+	Name     string
+	ClientID string
+}
+
+// Profile returns the profile called name. An empty name selects the default
+// profile. Profiles without a client_id use SPOTIFY_ID.
+func (c Config) Profile(name string) (Profile, error) {
+	return lookupProfile(c.Profiles, name)
+}
+
+func lookupProfile(profiles map[string]ProfileConfig, name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	if !validProfileName.MatchString(name) {
+		return Profile{}, fmt.Errorf("invalid profile name %q: use letters, digits, - and _", name)
+	}
+	p := Profile{Name: name, ClientID: profiles[name].ClientID}
+	if p.ClientID == "" {
+		p.ClientID = os.Getenv("SPOTIFY_ID")
+	}
+	return p, nil
+}
+
+// ProfileInfo describes a profile for `sptui profiles`.
+type ProfileInfo struct {
+	Name string
+	// Configured is set for profiles with a section in the config file.
+	Configured bool
+	// LoggedIn is set for profiles with a saved token.
+	LoggedIn bool
+}
+
+// ListProfiles returns the profiles that are configured or have a token in
+// store, sorted by name.
+func ListProfiles(cfg Config, store TokenStore) ([]ProfileInfo, error) {
+	infos := map[string]*ProfileInfo{}
+	add := func(name string) *ProfileInfo {
+		if infos[name] == nil {
+			infos[name] = &ProfileInfo{Name: name}
+		}
+		return infos[name]
+	}
+
+	for name := range cfg.Profiles {
+		add(name).Configured = true
+	}
+	names, err := store.Profiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		add(name).LoggedIn = true
+	}
+
+	var list []ProfileInfo
+	for _, info := range infos {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// RemoveProfile deletes the token of a profile from store. Its section in
+// the config file, if any, is left alone.
+func RemoveProfile(store TokenStore, name string) error {
+	p, err := lookupProfile(nil, name)
+	if err != nil {
+		return err
+	}
+	err = store.Delete(p.Name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("profile %q has no saved login", p.Name)
+	}
+	return err
+}
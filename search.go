@@ -0,0 +1,125 @@
+package sptui
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	searchLimit = 20
+	searchTypes = spotify.SearchTypeTrack | spotify.SearchTypeAlbum |
+		spotify.SearchTypeArtist | spotify.SearchTypePlaylist | spotify.SearchTypeShow
+)
+
+// Kinds of rows in the search tab
+const (
+	searchHeader = iota
+	searchTrack
+	searchAlbum
+	searchArtist
+	searchPlaylist
+	searchShow
+)
+
+type searchEntry struct {
+	kind int
+	name string
+	id   spotify.ID
+	uri  spotify.URI
+}
+
+// searchResults accumulates the pages of one query, grouped by type.
+type searchResults struct {
+	query     string
+	offset    int
+	more      bool
+	tracks    []spotify.FullTrack
+	albums    []spotify.SimpleAlbum
+	artists   []spotify.FullArtist
+	playlists []spotify.SimplePlaylist
+	shows     []spotify.FullShow
+	entries   []searchEntry
+}
+
+func newSearchResults(query string) *searchResults {
+	return &searchResults{query: query}
+}
+
+func (s *searchResults) merge(res *spotify.SearchResult) {
+	s.more = false
+	if res.Tracks != nil {
+		s.tracks = append(s.tracks, res.Tracks.Tracks...)
+		s.more = s.more || res.Tracks.Next != ""
+	}
+	if res.Albums != nil {
+		s.albums = append(s.albums, res.Albums.Albums...)
+		s.more = s.more || res.Albums.Next != ""
+	}
+	if res.Artists != nil {
+		s.artists = append(s.artists, res.Artists.Artists...)
+		s.more = s.more || res.Artists.Next != ""
+	}
+	if res.Playlists != nil {
+		s.playlists = append(s.playlists, res.Playlists.Playlists...)
+		s.more = s.more || res.Playlists.Next != ""
+	}
+	if res.Shows != nil {
+		s.shows = append(s.shows, res.Shows.Shows...)
+		s.more = s.more || res.Shows.Next != ""
+	}
+	s.offset += searchLimit
+	s.entries = s.buildEntries()
+}
+
+func (s *searchResults) buildEntries() []searchEntry {
+	var entries []searchEntry
+	group := func(title string, n int, entry func(i int) searchEntry) {
+		if n == 0 {
+			return
+		}
+		entries = append(entries, searchEntry{kind: searchHeader, name: "── " + title})
+		for i := 0; i < n; i++ {
+			entries = append(entries, entry(i))
+		}
+	}
+
+	group("Tracks", len(s.tracks), func(i int) searchEntry {
+		t := s.tracks[i]
+		return searchEntry{kind: searchTrack, name: t.Name + artistSuffix(t.Artists), id: t.ID, uri: t.URI}
+	})
+	group("Albums", len(s.albums), func(i int) searchEntry {
+		a := s.albums[i]
+		return searchEntry{kind: searchAlbum, name: a.Name + artistSuffix(a.Artists), id: a.ID, uri: a.URI}
+	})
+	group("Artists", len(s.artists), func(i int) searchEntry {
+		a := s.artists[i]
+		return searchEntry{kind: searchArtist, name: a.Name, id: a.ID, uri: a.URI}
+	})
+	group("Playlists", len(s.playlists), func(i int) searchEntry {
+		p := s.playlists[i]
+		return searchEntry{kind: searchPlaylist, name: p.Name, id: p.ID, uri: p.URI}
+	})
+	group("Podcasts", len(s.shows), func(i int) searchEntry {
+		sh := s.shows[i]
+		return searchEntry{kind: searchShow, name: sh.Name, id: sh.ID, uri: sh.URI}
+	})
+	return entries
+}
+
+func (s *searchResults) items() []list.Item {
+	if len(s.entries) == 0 {
+		return []list.Item{item{title: "No results for \"" + s.query + "\""}}
+	}
+	var itemList []list.Item
+	for _, e := range s.entries {
+		itemList = append(itemList, item{title: e.name})
+	}
+	return itemList
+}
+
+func artistSuffix(artists []spotify.SimpleArtist) string {
+	if len(artists) == 0 {
+		return ""
+	}
+	return " (" + artists[0].Name + ")"
+}
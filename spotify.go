@@ -2,6 +2,7 @@ package sptui
 
 import (
 	"context"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/zmb3/spotify/v2"
@@ -13,30 +14,74 @@ type ErrMsg struct {
 
 type AlbumMsg struct {
 	Albums *spotify.SavedAlbumPage
+	// Cached is set when the page comes from the disk cache and may be out
+	// of date.
+	Cached bool
 }
 
 type PlaylistMsg struct {
 	Playlists *spotify.SimplePlaylistPage
+	// Cached is set when the page comes from the disk cache and may be out
+	// of date.
+	Cached bool
 }
 
 type ShowMsg struct {
 	Shows *spotify.SavedShowPage
+	// Cached is set when the page comes from the disk cache and may be out
+	// of date.
+	Cached bool
 }
 
 type AlbumDetailMsg struct {
 	Album *spotify.FullAlbum
+	// Cached is set when it comes from the disk cache and may be out of
+	// date.
+	Cached bool
+}
+
+// AlbumTracksMsg is a further page of an album's tracks.
+type AlbumTracksMsg struct {
+	AlbumID spotify.ID
+	Tracks  *spotify.SimpleTrackPage
 }
 
 type ShowDetailMsg struct {
 	Show *spotify.FullShow
 }
 
+// ShowEpisodesMsg is a further page of a show's episodes.
+type ShowEpisodesMsg struct {
+	ShowID   spotify.ID
+	Episodes *spotify.SimpleEpisodePage
+}
+
+type EpisodeMsg struct {
+	Episode *spotify.EpisodePage
+}
+
 type PlaylistDetailMsg struct {
 	Playlist *spotify.FullPlaylist
+	// Cached is set when it comes from the disk cache and may be out of
+	// date.
+	Cached bool
+}
+
+// PlaylistTracksMsg is a further page of a playlist's tracks.
+type PlaylistTracksMsg struct {
+	PlaylistID spotify.ID
+	Tracks     *spotify.PlaylistTrackPage
+}
+
+type SearchMsg struct {
+	Query  string
+	Result *spotify.SearchResult
+	// request identifies the search the page belongs to.
+	request int
 }
 
-type CurrentlyPlayingMsg struct {
-	Track *spotify.CurrentlyPlaying
+type PlayerStateMsg struct {
+	State *spotify.PlayerState
 }
 
 type PlayerDevicesMsg struct {
@@ -46,9 +91,27 @@ type PlayerDevicesMsg struct {
 type PlaybackMsg struct {
 }
 
-func FetchAlbumsCmd(client *spotify.Client, opts ...spotify.RequestOption) tea.Cmd {
+type QueueMsg struct {
+	Queue *spotify.Queue
+}
+
+type QueuedMsg struct {
+	URI spotify.URI
+}
+
+// requestTimeout bounds each Web API call, retries included.
+const requestTimeout = 30 * time.Second
+
+// request returns the context of a Web API call made for parent.
+func request(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, requestTimeout)
+}
+
+func FetchAlbumsCmd(client Backend, offset int) tea.Cmd {
 	return func() tea.Msg {
-		albums, err := client.CurrentUsersAlbums(context.Background(), opts...)
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		albums, err := client.CurrentUsersAlbums(ctx, offset)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -56,9 +119,11 @@ func FetchAlbumsCmd(client *spotify.Client, opts ...spotify.RequestOption) tea.C
 	}
 }
 
-func FetchPlaylistsCmd(client *spotify.Client, opts ...spotify.RequestOption) tea.Cmd {
+func FetchPlaylistsCmd(client Backend, offset int) tea.Cmd {
 	return func() tea.Msg {
-		playlist, err := client.CurrentUsersPlaylists(context.Background(), opts...)
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		playlist, err := client.CurrentUsersPlaylists(ctx, offset)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -66,9 +131,11 @@ func FetchPlaylistsCmd(client *spotify.Client, opts ...spotify.RequestOption) te
 	}
 }
 
-func FetchShowsCmd(client *spotify.Client, opts ...spotify.RequestOption) tea.Cmd {
+func FetchShowsCmd(client Backend, offset int) tea.Cmd {
 	return func() tea.Msg {
-		shows, err := client.CurrentUsersShows(context.Background(), opts...)
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		shows, err := client.CurrentUsersShows(ctx, offset)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -76,9 +143,11 @@ func FetchShowsCmd(client *spotify.Client, opts ...spotify.RequestOption) tea.Cm
 	}
 }
 
-func GetAlbumCmd(client *spotify.Client, id spotify.ID) tea.Cmd {
+func GetAlbumCmd(ctx context.Context, client Backend, id spotify.ID) tea.Cmd {
 	return func() tea.Msg {
-		album, err := client.GetAlbum(context.Background(), id)
+		ctx, cancel := request(ctx)
+		defer cancel()
+		album, err := client.GetAlbum(ctx, id)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -86,9 +155,23 @@ func GetAlbumCmd(client *spotify.Client, id spotify.ID) tea.Cmd {
 	}
 }
 
-func GetShowCmd(client *spotify.Client, id spotify.ID) tea.Cmd {
+func FetchAlbumTracksCmd(ctx context.Context, client Backend, id spotify.ID, offset int) tea.Cmd {
 	return func() tea.Msg {
-		show, err := client.GetShow(context.Background(), id)
+		ctx, cancel := request(ctx)
+		defer cancel()
+		tracks, err := client.GetAlbumTracks(ctx, id, offset)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return AlbumTracksMsg{AlbumID: id, Tracks: tracks}
+	}
+}
+
+func GetShowCmd(ctx context.Context, client Backend, id spotify.ID) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(ctx)
+		defer cancel()
+		show, err := client.GetShow(ctx, id)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -96,9 +179,35 @@ func GetShowCmd(client *spotify.Client, id spotify.ID) tea.Cmd {
 	}
 }
 
-func GetPlaylistCmd(client *spotify.Client, id spotify.ID) tea.Cmd {
+func FetchShowEpisodesCmd(ctx context.Context, client Backend, id spotify.ID, offset int) tea.Cmd {
 	return func() tea.Msg {
-		playlist, err := client.GetPlaylist(context.Background(), id)
+		ctx, cancel := request(ctx)
+		defer cancel()
+		episodes, err := client.GetShowEpisodes(ctx, id, offset)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return ShowEpisodesMsg{ShowID: id, Episodes: episodes}
+	}
+}
+
+func GetEpisodeCmd(client Backend, id spotify.ID) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		episode, err := client.GetEpisode(ctx, id)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return EpisodeMsg{Episode: episode}
+	}
+}
+
+func GetPlaylistCmd(ctx context.Context, client Backend, id spotify.ID) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(ctx)
+		defer cancel()
+		playlist, err := client.GetPlaylist(ctx, id)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -106,21 +215,47 @@ func GetPlaylistCmd(client *spotify.Client, id spotify.ID) tea.Cmd {
 	}
 }
 
-func GetCurrentlyPlayingTrackCmd(client *spotify.Client) tea.Cmd {
+func FetchPlaylistTracksCmd(ctx context.Context, client Backend, id spotify.ID, offset int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(ctx)
+		defer cancel()
+		tracks, err := client.GetPlaylistTracks(ctx, id, offset)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlaylistTracksMsg{PlaylistID: id, Tracks: tracks}
+	}
+}
+
+func SearchCmd(client Backend, query string, offset, id int) tea.Cmd {
 	return func() tea.Msg {
-		track, err := client.PlayerCurrentlyPlaying(context.Background())
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		result, err := client.Search(ctx, query, offset)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
+		return SearchMsg{Query: query, Result: result, request: id}
+	}
+}
 
-		//TODO: podcast support
-		return CurrentlyPlayingMsg{Track: track}
+func GetPlayerStateCmd(client Backend) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		state, err := client.PlayerState(ctx)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlayerStateMsg{State: state}
 	}
 }
 
-func GetAvailableDevicesCmd(client *spotify.Client) tea.Cmd {
+func GetAvailableDevicesCmd(client Backend) tea.Cmd {
 	return func() tea.Msg {
-		device, err := client.PlayerDevices(context.Background())
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		device, err := client.PlayerDevices(ctx)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -128,11 +263,11 @@ func GetAvailableDevicesCmd(client *spotify.Client) tea.Cmd {
 	}
 }
 
-func StartPlaybackCmd(client *spotify.Client, opts *spotify.PlayOptions) tea.Cmd {
+func StartPlaybackCmd(client Backend, opts *spotify.PlayOptions) tea.Cmd {
 	return func() tea.Msg {
-		err := client.PlayOpt(context.Background(),
-			opts,
-		)
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.PlayOpt(ctx, opts)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -140,9 +275,11 @@ func StartPlaybackCmd(client *spotify.Client, opts *spotify.PlayOptions) tea.Cmd
 	}
 }
 
-func PausePlaybackCmd(client *spotify.Client) tea.Cmd {
+func TransferPlaybackCmd(client Backend, deviceID spotify.ID, play bool) tea.Cmd {
 	return func() tea.Msg {
-		err := client.Pause(context.Background())
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.TransferPlayback(ctx, deviceID, play)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -150,9 +287,11 @@ func PausePlaybackCmd(client *spotify.Client) tea.Cmd {
 	}
 }
 
-func NextPlaybackCmd(client *spotify.Client) tea.Cmd {
+func PausePlaybackCmd(client Backend) tea.Cmd {
 	return func() tea.Msg {
-		err := client.Next(context.Background())
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Pause(ctx)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
@@ -160,12 +299,98 @@ func NextPlaybackCmd(client *spotify.Client) tea.Cmd {
 	}
 }
 
-func PreviousPlaybackCmd(client *spotify.Client) tea.Cmd {
+func NextPlaybackCmd(client Backend) tea.Cmd {
 	return func() tea.Msg {
-		err := client.Previous(context.Background())
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Next(ctx)
 		if err != nil {
 			return ErrMsg{Err: err}
 		}
 		return PlaybackMsg{}
 	}
 }
+
+func PreviousPlaybackCmd(client Backend) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Previous(ctx)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlaybackMsg{}
+	}
+}
+
+func SeekCmd(client Backend, positionMs int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Seek(ctx, positionMs)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlaybackMsg{}
+	}
+}
+
+func VolumeCmd(client Backend, percent int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Volume(ctx, percent)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlaybackMsg{}
+	}
+}
+
+func ShuffleCmd(client Backend, shuffle bool) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Shuffle(ctx, shuffle)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlaybackMsg{}
+	}
+}
+
+func RepeatCmd(client Backend, state string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.Repeat(ctx, state)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return PlaybackMsg{}
+	}
+}
+
+func GetQueueCmd(client Backend) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		queue, err := client.GetQueue(ctx)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return QueueMsg{Queue: queue}
+	}
+}
+
+func QueueItemCmd(client Backend, uri spotify.URI) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := request(context.Background())
+		defer cancel()
+		err := client.QueueItem(ctx, uri)
+		if err != nil {
+			return ErrMsg{Err: err}
+		}
+		return QueuedMsg{URI: uri}
+	}
+}
@@ -1,25 +1,193 @@
 package sptui
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
-var (
-	docStyle         = lipgloss.NewStyle().Padding(1, 2, 1, 2)
-	highlightColor   = lipgloss.AdaptiveColor{Light: "#874BFD", Dark: "#7D56F4"}
-	inactiveTabStyle = lipgloss.NewStyle().Border(inactiveTabBorder, true).
-				BorderForeground(highlightColor).Padding(0, 1)
-	activeTabStyle = inactiveTabStyle.Copy().Border(activeTabBorder, true)
-	windowStyle    = lipgloss.NewStyle().
-			BorderForeground(highlightColor).
-			Padding(1, 5).
-			Align(lipgloss.Left).
-			Border(lipgloss.NormalBorder()).
-			UnsetBorderTop()
-	errStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("216"))
-	helpStyle         = list.DefaultStyles().HelpStyle.PaddingLeft(2)
-	itemStyle         = lipgloss.NewStyle().PaddingLeft(2)
-	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("170"))
-	paginationStyle   = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
-)
+// Theme is a named color scheme for the whole UI.
+type Theme struct {
+	Name string
+
+	Accent     lipgloss.TerminalColor // tab and window borders
+	Selected   lipgloss.TerminalColor
+	Error      lipgloss.TerminalColor
+	TrackTitle lipgloss.TerminalColor
+	Title      lipgloss.TerminalColor // list title background
+	TitleText  lipgloss.TerminalColor
+	HelpKey    lipgloss.TerminalColor
+	HelpDesc   lipgloss.TerminalColor
+
+	// ProgressFrom and ProgressTo are the ends of the progress bar gradient.
+	// An empty ProgressTo fills the bar with ProgressFrom, and an empty
+	// ProgressFrom draws it without color.
+	ProgressFrom string
+	ProgressTo   string
+
+	// Bold and Reverse mark the selection and list titles without relying
+	// on color.
+	Bold    bool
+	Reverse bool
+}
+
+var themes = map[string]Theme{
+	"default": {
+		Name:         "default",
+		Accent:       lipgloss.AdaptiveColor{Light: "#874BFD", Dark: "#7D56F4"},
+		Selected:     lipgloss.Color("170"),
+		Error:        lipgloss.Color("216"),
+		TrackTitle:   lipgloss.Color("212"),
+		Title:        lipgloss.Color("62"),
+		TitleText:    lipgloss.Color("230"),
+		HelpKey:      lipgloss.AdaptiveColor{Light: "#909090", Dark: "#626262"},
+		HelpDesc:     lipgloss.AdaptiveColor{Light: "#B2B2B2", Dark: "#4A4A4A"},
+		ProgressFrom: "#5A56E0",
+		ProgressTo:   "#EE6FF8",
+	},
+	"light": {
+		Name:         "light",
+		Accent:       lipgloss.Color("#5A3FC0"),
+		Selected:     lipgloss.Color("127"),
+		Error:        lipgloss.Color("160"),
+		TrackTitle:   lipgloss.Color("125"),
+		Title:        lipgloss.Color("#5A3FC0"),
+		TitleText:    lipgloss.Color("231"),
+		HelpKey:      lipgloss.Color("240"),
+		HelpDesc:     lipgloss.Color("245"),
+		ProgressFrom: "#5A3FC0",
+		ProgressTo:   "#B0217C",
+	},
+	"high-contrast": {
+		Name:         "high-contrast",
+		Accent:       lipgloss.AdaptiveColor{Light: "0", Dark: "15"},
+		Selected:     lipgloss.AdaptiveColor{Light: "4", Dark: "14"},
+		Error:        lipgloss.AdaptiveColor{Light: "1", Dark: "9"},
+		TrackTitle:   lipgloss.AdaptiveColor{Light: "0", Dark: "15"},
+		Title:        lipgloss.AdaptiveColor{Light: "0", Dark: "15"},
+		TitleText:    lipgloss.AdaptiveColor{Light: "15", Dark: "0"},
+		HelpKey:      lipgloss.AdaptiveColor{Light: "0", Dark: "15"},
+		HelpDesc:     lipgloss.AdaptiveColor{Light: "8", Dark: "7"},
+		ProgressFrom: "12",
+		Bold:         true,
+	},
+	"monochrome": {
+		Name:       "monochrome",
+		Accent:     lipgloss.NoColor{},
+		Selected:   lipgloss.NoColor{},
+		Error:      lipgloss.NoColor{},
+		TrackTitle: lipgloss.NoColor{},
+		Title:      lipgloss.NoColor{},
+		TitleText:  lipgloss.NoColor{},
+		HelpKey:    lipgloss.NoColor{},
+		HelpDesc:   lipgloss.NoColor{},
+		Bold:       true,
+		Reverse:    true,
+	},
+}
+
+// LookupTheme returns the built-in theme called name. An empty name selects
+// monochrome when NO_COLOR is set and the default theme otherwise.
+func LookupTheme(name string) (Theme, error) {
+	if name == "" {
+		name = "default"
+		if os.Getenv("NO_COLOR") != "" {
+			name = "monochrome"
+		}
+	}
+	t, ok := themes[name]
+	if !ok {
+		return t, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	return t, nil
+}
+
+// ThemeNames lists the built-in themes.
+func ThemeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Styles holds the lipgloss styles derived from a Theme.
+type Styles struct {
+	doc          lipgloss.Style
+	inactiveTab  lipgloss.Style
+	activeTab    lipgloss.Style
+	window       lipgloss.Style
+	trackWindow  lipgloss.Style
+	err          lipgloss.Style
+	item         lipgloss.Style
+	selectedItem lipgloss.Style
+	trackTitle   lipgloss.Style
+	status       lipgloss.Style
+	list         list.Styles
+	help         help.Styles
+	progress     []progress.Option
+}
+
+func NewStyles(t Theme) Styles {
+	s := Styles{}
+	s.doc = lipgloss.NewStyle().Padding(1, 2, 1, 2)
+	s.inactiveTab = lipgloss.NewStyle().Border(inactiveTabBorder, true).
+		BorderForeground(t.Accent).Padding(0, 1)
+	s.activeTab = s.inactiveTab.Copy().Border(activeTabBorder, true)
+	s.window = lipgloss.NewStyle().
+		BorderForeground(t.Accent).
+		Padding(1, 5).
+		Align(lipgloss.Left).
+		Border(lipgloss.NormalBorder()).
+		UnsetBorderTop()
+	s.trackWindow = lipgloss.NewStyle().
+		BorderForeground(t.Accent).
+		Padding(1, 5).
+		Align(lipgloss.Left).
+		Border(lipgloss.RoundedBorder())
+	s.err = lipgloss.NewStyle().Foreground(t.Error)
+	s.item = lipgloss.NewStyle().PaddingLeft(2)
+	s.selectedItem = lipgloss.NewStyle().PaddingLeft(2).Foreground(t.Selected).
+		Bold(t.Bold).Reverse(t.Reverse)
+	s.trackTitle = lipgloss.NewStyle().Foreground(t.TrackTitle).Bold(t.Bold)
+	s.status = lipgloss.NewStyle().Foreground(t.HelpKey)
+
+	s.list = list.DefaultStyles()
+	s.list.Title = s.list.Title.Copy().
+		Background(t.Title).Foreground(t.TitleText).Reverse(t.Reverse)
+	s.list.PaginationStyle = s.list.PaginationStyle.Copy().PaddingLeft(4)
+	s.list.HelpStyle = s.list.HelpStyle.Copy().Padding(0, 0, 0, 2)
+	s.list.ActivePaginationDot = s.list.ActivePaginationDot.Copy().Foreground(t.HelpKey)
+	s.list.InactivePaginationDot = s.list.InactivePaginationDot.Copy().Foreground(t.HelpDesc)
+	s.list.FilterPrompt = s.list.FilterPrompt.Copy().Foreground(t.Selected).Bold(t.Bold)
+	s.list.FilterCursor = s.list.FilterCursor.Copy().Foreground(t.Accent)
+
+	s.help = help.New().Styles
+	s.help.ShortKey = s.help.ShortKey.Copy().Foreground(t.HelpKey)
+	s.help.ShortDesc = s.help.ShortDesc.Copy().Foreground(t.HelpDesc)
+	s.help.FullKey = s.help.FullKey.Copy().Foreground(t.HelpKey)
+	s.help.FullDesc = s.help.FullDesc.Copy().Foreground(t.HelpDesc)
+	s.help.ShortSeparator = s.help.ShortSeparator.Copy().Foreground(t.HelpDesc)
+	s.help.FullSeparator = s.help.FullSeparator.Copy().Foreground(t.HelpDesc)
+	s.help.Ellipsis = s.help.Ellipsis.Copy().Foreground(t.HelpDesc)
+
+	switch {
+	case t.ProgressFrom == "":
+		s.progress = []progress.Option{progress.WithColorProfile(termenv.Ascii)}
+	case t.ProgressTo == "":
+		s.progress = []progress.Option{progress.WithSolidFill(t.ProgressFrom)}
+	default:
+		s.progress = []progress.Option{progress.WithScaledGradient(t.ProgressFrom, t.ProgressTo)}
+	}
+	return s
+}
+
+var defaultStyles = NewStyles(themes["default"])
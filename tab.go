@@ -1,10 +1,14 @@
 package sptui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -17,14 +21,23 @@ const (
 	PLAYLIST = iota
 	ALBUM
 	PODCAST
+	SEARCH
 )
 
+// NODETAIL is the detail of a tracklist that has not loaded yet.
+const NODETAIL = -1
+
 // Screen Mode
 const (
 	TOP = iota
 	TRACKLIST
 )
 
+const (
+	seekStep   = 10 * time.Second
+	volumeStep = 5
+)
+
 // Text Input Mode
 const (
 	NONE = iota
@@ -33,7 +46,8 @@ const (
 )
 
 var (
-	loading           = PadOrTruncate("Loading...", listWidth)
+	loading           = "Loading..."
+	searchHint        = ":search <query>"
 	inactiveTabBorder = tabBorderWithBottom("┴", "─", "┴")
 	activeTabBorder   = tabBorderWithBottom("┘", " ", "└")
 )
@@ -49,30 +63,78 @@ type TabModel struct {
 	textInput TextModel
 	textMode  int
 
-	help HelpModel
+	help   HelpModel
+	styles Styles
+	layout layout
 
 	depth int
 
-	client     *spotify.Client
+	client     Backend
 	authorized bool
+	// offline is set when the backend was given with WithBackend, so there
+	// is no account to log in to.
+	offline  bool
+	login    LoginConfig
+	tokens   TokenStore
+	profile  Profile
+	profiles map[string]ProfileConfig
+	// cache is nil when there is no account, as with WithBackend.
+	cache *libraryCache
+	// loginPaste completes a headless login with the pasted redirect URL.
+	loginPaste func(pasted string) tea.Cmd
 
 	albums        *spotify.SavedAlbumPage
 	playlists     *spotify.SimplePlaylistPage
 	shows         *spotify.SavedShowPage
 	selectedAlbum *spotify.FullAlbum
-	//TODO
-	episodes         []spotify.EpisodePage
+	// selectedShow holds every episode loaded so far in Episodes.
+	selectedShow     *spotify.FullShow
 	selectedPlaylist *spotify.FullPlaylist
+	// detail is the tab kind of the tracklist being shown
+	detail int
+	// detailCached is set while the tracklist shows a cached album or
+	// playlist that is being fetched again.
+	detailCached bool
+	// refreshingAlbum and refreshingPlaylist collect the tracks of the copy
+	// that replaces the cached one being shown.
+	refreshingAlbum    *spotify.FullAlbum
+	refreshingPlaylist *spotify.FullPlaylist
+	// detailRequest identifies the tracklist being shown. Its requests are
+	// made with detailCtx, which is cancelled when it is left.
+	detailRequest int
+	detailCtx     context.Context
+	cancelDetail  context.CancelFunc
+
+	search *searchResults
+	// searchRequest identifies the search being shown, so that pages of an
+	// earlier one are dropped even if it was for the same query.
+	searchRequest int
 
 	currentlyPlaying *spotify.CurrentlyPlaying
+	playerState      *spotify.PlayerState
 	currentDevice    *spotify.PlayerDevice
 	devices          []spotify.PlayerDevice
 	deviceMode       bool
+	queueMode        bool
+
+	// playingEpisode has the show of the current item when it is an episode.
+	playingEpisode *spotify.EpisodePage
+
+	observers []PlayerObserver
+
+	player PlayerConfig
+	// pollID identifies the polling started by the last login.
+	pollID int
 }
 
 func (m TabModel) Init() tea.Cmd {
+	if m.client != nil {
+		return func() tea.Msg {
+			return AuthMsg{m.client}
+		}
+	}
 	return tea.Batch(
-		loginCmd(),
+		loginCmd(m.login, m.tokens, m.profile),
 	)
 }
 
@@ -81,22 +143,70 @@ func (m TabModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if !m.authorized {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			switch keypress := msg.String(); keypress {
-			case "ctrl+c", "q":
+			if m.loginPaste != nil {
+				return updateLoginPaste(m, msg)
+			}
+			if key.Matches(msg, m.help.KeyMap.Quit) {
 				return m, tea.Quit
 			}
 
+		case tea.WindowSizeMsg:
+			return m.resize(msg), nil
+
+		case ControlMsg:
+			if msg.Reply != nil {
+				msg.Reply(nil, errNotLoggedIn)
+			}
+			return m, nil
+
+		case LoginMsg:
+			if msg.Paste != nil {
+				m.loginPaste = msg.Paste
+				m.textInput = NewTextModel()
+				m.textInput.textInput.Prompt = "> "
+				m.textInput.textInput.CharLimit = 0
+				m.textInput.textInput.Width = m.layout.barWidth() - 2
+				return m, tea.Println("Please log in to Spotify by visiting the following page in a browser: " + msg.URL)
+			}
+			return m, tea.Batch(
+				tea.Println("Please log in to Spotify by visiting the following page in your browser: "+msg.URL),
+				msg.Wait,
+			)
+
+		case AuthErrMsg:
+			// A bad paste can be retried.
+			if m.loginPaste != nil {
+				m.textInput.textInput.SetValue("")
+				return m, tea.Println("Error: " + msg.Err.Error())
+			}
+			return m, tea.Sequence(tea.Println("Error: "+msg.Err.Error()), tea.Quit)
+
 		case AuthMsg:
 			//Clear screen
 			fmt.Print("\033[H\033[2J")
 			m.authorized = true
 			m.client = msg.client
-			return m, tea.Batch(
-				FetchAlbumsCmd(m.client),
-				GetCurrentlyPlayingTrackCmd(m.client),
-				FetchPlaylistsCmd(m.client),
-				FetchShowsCmd(m.client),
-			)
+			m.loginPaste = nil
+			m.textInput = NewTextModel()
+			// After logging in again the library is already loaded.
+			var cmds []tea.Cmd
+			if m.albums == nil || m.playlists == nil || m.shows == nil {
+				// Show the library from the last run until it is fetched.
+				cmds = append(cmds, m.cache.loadLibraryCmd())
+			}
+			if m.albums == nil {
+				cmds = append(cmds, FetchAlbumsCmd(m.client, 0))
+			}
+			cmds = append(cmds, GetPlayerStateCmd(m.client))
+			m.pollID++
+			cmds = append(cmds, pollTickCmd(m.pollID, m.player.pollInterval()))
+			if m.playlists == nil {
+				cmds = append(cmds, FetchPlaylistsCmd(m.client, 0))
+			}
+			if m.shows == nil {
+				cmds = append(cmds, FetchShowsCmd(m.client, 0))
+			}
+			return m, tea.Batch(cmds...)
 		default:
 			return m, nil
 		}
@@ -113,71 +223,163 @@ func (m TabModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return m.resize(msg), nil
+
 	case tea.KeyMsg:
-		switch keypress := msg.String(); keypress {
-		case ":":
+		// The command line always submits with enter, whatever Select is bound to.
+		if m.textMode == INPUT {
+			if msg.Type == tea.KeyEnter {
+				return execTxtCommand(m)
+			}
+			return m, nil
+		}
+		if m.textMode == ERROR && msg.Type == tea.KeyEnter {
+			m.textMode = NONE
+			m.textInput = NewTextModel()
+			return m, nil
+		}
+
+		// While a filter is being typed keys belong to it, not to the
+		// bindings below.
+		if l, ok := m.activeList(); ok && l.Filtering(msg, m.help.KeyMap) {
+			return m.updateActiveList(msg)
+		}
+
+		keys := m.help.KeyMap
+		switch {
+		case key.Matches(msg, keys.Toggle):
+			return togglePlayback(m)
+		case key.Matches(msg, keys.Next):
+			return m, NextPlaybackCmd(m.client)
+		case key.Matches(msg, keys.Prev):
+			return m, PreviousPlaybackCmd(m.client)
+		case key.Matches(msg, keys.Device):
+			return m, GetAvailableDevicesCmd(m.client)
+		case key.Matches(msg, keys.Queue):
+			return m, GetQueueCmd(m.client)
+		case key.Matches(msg, keys.SeekBackward):
+			return seekBy(m, -seekStep)
+		case key.Matches(msg, keys.SeekForward):
+			return seekBy(m, seekStep)
+		case key.Matches(msg, keys.VolumeDown):
+			return changeVolume(m, -volumeStep)
+		case key.Matches(msg, keys.VolumeUp):
+			return changeVolume(m, volumeStep)
+		case key.Matches(msg, keys.Shuffle):
+			return toggleShuffle(m)
+		case key.Matches(msg, keys.Repeat):
+			return cycleRepeat(m)
+		case key.Matches(msg, keys.Help):
+			m.help.help.ShowAll = !m.help.help.ShowAll
+			return m.resize(tea.WindowSizeMsg{Width: m.layout.width, Height: m.layout.height}), nil
+		case key.Matches(msg, keys.AddToQueue):
+			if m.depth == TRACKLIST && !m.deviceMode && !m.queueMode {
+				return enqueueTrack(m)
+			}
+
+		case key.Matches(msg, keys.Command):
 			if m.textMode != NONE {
 				return m, nil
 			}
 
 			m.textMode = INPUT
 			m.textInput.textInput.Prompt = ":"
+			m.textInput.textInput.Width = m.layout.barWidth() - 2
 			return m, nil
 
-		case "enter":
-			if m.textMode == ERROR {
-				m.textMode = NONE
-				m.textInput = NewTextModel()
-				return m, nil
-			}
-
-			if m.textMode == INPUT {
-				return execTxtCommand(m)
-			}
-
+		case key.Matches(msg, keys.Select):
 			if m.deviceMode {
 				return playOnDevice(m)
 			}
 
+			if m.queueMode {
+				return m, nil
+			}
+
 			if m.depth == TRACKLIST {
 				return playTrack(m)
 			}
 		}
 
-	case CurrentlyPlayingMsg:
-		if msg.Track.Item == nil {
-			m.progress = BarModel{}
+	case list.FilterMatchesMsg:
+		return m.updateActiveList(msg)
+
+	case EpisodeMsg:
+		return setPlayingEpisode(m, msg.Episode)
+
+	case detailMsg:
+		if msg.request != m.detailRequest {
 			return m, nil
 		}
-		m.currentlyPlaying = msg.Track
+		return m.Update(msg.msg)
 
-		tickID := uuid.New().String()
-		m.progress = NewBarModel(BarConfig{
-			TickID:     tickID,
-			Percent:    float64(msg.Track.Progress) / float64(msg.Track.Item.Duration),
-			IsPlaying:  msg.Track.Playing,
-			DeltaDur:   float64(1000) / float64(msg.Track.Item.Duration),
-			TrackTitle: msg.Track.Item.Name + " (" + msg.Track.Item.Artists[0].Name + ")",
-		})
+	case ControlMsg:
+		return control(m, msg)
+
+	case pollTickMsg, pollStateMsg:
+		return updatePoll(m, msg)
+
+	case AlbumMsg, PlaylistMsg, ShowMsg:
+		return libraryUpdate(m, msg)
+
+	case SearchMsg:
+		return searchUpdate(m, msg)
 
-		return m, tea.Batch(tickCmd(tickID),
-			AnimTextTickCmd(tickID, 2000*time.Millisecond))
+	case PlayerStateMsg:
+		m.playerState = msg.State
+		m.notifyObservers()
+		if msg.State == nil {
+			m.currentlyPlaying = nil
+			m.progress = BarModel{}
+			return m, nil
+		}
+		// Follow playback that was moved to another device.
+		if msg.State.Device.ID != "" {
+			device := msg.State.Device
+			m.currentDevice = &device
+		}
+		return setCurrentlyPlaying(m, &msg.State.CurrentlyPlaying)
 
 	case PlayerDevicesMsg:
-		m.listView = NewListModel(playerDeviceToItemList(msg.PlayerDevices),
+		m.closeDetail()
+		m.listView = m.newListModel(playerDeviceToItemList(msg.PlayerDevices),
 			WithTitle("Select Device"),
 		)
 		m.deviceMode = true
+		m.queueMode = false
 		m.devices = msg.PlayerDevices
 		m.depth = TRACKLIST
 
+	case QueueMsg:
+		m.closeDetail()
+		m.listView = m.newListModel(queueToItemList(msg.Queue),
+			WithTitle("Queue"),
+			WithColumns(queueColumns...),
+		)
+		m.queueMode = true
+		m.deviceMode = false
+		m.depth = TRACKLIST
+
+	case QueuedMsg:
+		if m.queueMode {
+			return m, GetQueueCmd(m.client)
+		}
+		return m, nil
+
 	case PlaybackMsg:
-		//TODO: Fix
-		time.Sleep(500 * time.Millisecond)
-		return m, GetCurrentlyPlayingTrackCmd(m.client)
+		client := m.client
+		return m, tea.Tick(playbackDelay, func(time.Time) tea.Msg {
+			return GetPlayerStateCmd(client)()
+		})
 
 	case ErrMsg:
+		if errors.Is(msg.Err, ErrTokenRevoked) {
+			m.authorized = false
+			return m, loginCmd(m.login, m.tokens, m.profile)
+		}
 		m.textInput.textInput.Prompt = "E: "
+		m.textInput.textInput.Width = m.layout.barWidth() - 4
 		m.textMode = ERROR
 		m.textInput.textInput.SetValue(msg.Err.Error())
 		return m, nil
@@ -195,8 +397,142 @@ func (m TabModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 }
 
+// updateLoginPaste edits the pasted redirect URL of a headless login and
+// submits it with enter.
+func updateLoginPaste(m TabModel, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyEnter {
+		return m, m.loginPaste(m.textInput.textInput.Value())
+	}
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.UpdateText(msg)
+	return m, cmd
+}
+
+// resize lays the UI out for the new terminal size.
+func (m TabModel) resize(msg tea.WindowSizeMsg) TabModel {
+	m.layout = newLayout(msg.Width, msg.Height).withHelp(m.help.height())
+	w, h := m.layout.listWidth, m.layout.listHeight
+
+	tabContents := make([]ListModel, len(m.tabContents))
+	for i, l := range m.tabContents {
+		l.SetSize(w, h)
+		tabContents[i] = l
+	}
+	m.tabContents = tabContents
+	m.listView.SetSize(w, h)
+	m.progress.Resize(m.layout.docPadX, m.layout.barWidth())
+	m.help.help.Width = m.layout.barWidth()
+	return m
+}
+
+// setCurrentlyPlaying shows track in the progress bar, taking shuffle,
+// repeat and volume from the last player state. The bar is only restarted
+// when the item or its title changes.
+func setCurrentlyPlaying(m TabModel, track *spotify.CurrentlyPlaying) (tea.Model, tea.Cmd) {
+	if track.Item == nil {
+		m.currentlyPlaying = nil
+		m.progress = BarModel{}
+		return m, nil
+	}
+	prev := m.currentlyPlaying
+	m.currentlyPlaying = track
+
+	var fetchEpisode tea.Cmd
+	if isEpisode(track.Item) && (m.playingEpisode == nil || m.playingEpisode.URI != track.Item.URI) {
+		m.playingEpisode = nil
+		fetchEpisode = GetEpisodeCmd(m.client, track.Item.ID)
+	}
+
+	conf := BarConfig{
+		IsPlaying:  track.Playing,
+		TrackTitle: m.playingTitle(),
+		Styles:     m.styles,
+		Indent:     m.layout.docPadX,
+		Width:      m.layout.barWidth(),
+		Volume:     -1,
+	}
+	// Local files, ads and some episodes have no duration and leave the bar
+	// empty.
+	if d := track.Item.Duration; d > 0 {
+		conf.Percent = float64(track.Progress) / float64(d)
+		conf.DeltaDur = float64(1000) / float64(d)
+	}
+	if m.playerState != nil {
+		conf.Shuffle = m.playerState.ShuffleState
+		conf.Repeat = m.playerState.RepeatState
+		conf.Volume = m.playerState.Device.Volume
+	}
+	if m.progress.tickID != "" && prev != nil && prev.Item != nil &&
+		prev.Item.URI == track.Item.URI && m.progress.trackTitle == conf.TrackTitle {
+		return m, tea.Batch(m.progress.sync(conf), fetchEpisode)
+	}
+	conf.TickID = uuid.New().String()
+	m.progress = NewBarModel(conf)
+
+	return m, tea.Batch(tickCmd(conf.TickID),
+		AnimTextTickCmd(conf.TickID, 2000*time.Millisecond), fetchEpisode)
+}
+
+// playingTitle names the current item and its artist, or its show for an
+// episode.
+func (m TabModel) playingTitle() string {
+	item := m.currentlyPlaying.Item
+	switch {
+	case isEpisode(item) && m.playingEpisode != nil:
+		return item.Name + " (" + m.playingEpisode.Show.Name + ")"
+	case len(item.Artists) > 0:
+		return item.Name + " (" + item.Artists[0].Name + ")"
+	default:
+		return item.Name
+	}
+}
+
+// setPlayingEpisode records the details of the episode being played and
+// refreshes its resume point in the open show.
+func setPlayingEpisode(m TabModel, episode *spotify.EpisodePage) (tea.Model, tea.Cmd) {
+	if m.currentlyPlaying == nil || m.currentlyPlaying.Item == nil ||
+		m.currentlyPlaying.Item.URI != episode.URI {
+		return m, nil
+	}
+	m.playingEpisode = episode
+
+	var cmd tea.Cmd
+	if m.selectedShow != nil {
+		episodes := m.selectedShow.Episodes.Episodes
+		for i := range episodes {
+			if episodes[i].URI == episode.URI {
+				episodes[i].ResumePoint = episode.ResumePoint
+				if m.detail == PODCAST && m.depth == TRACKLIST && !m.deviceMode && !m.queueMode {
+					cmd = m.listView.SetItems(episodesToItemList(episodes))
+				}
+			}
+		}
+	}
+	// Fetch the state again rather than reuse the old progress.
+	return m, tea.Batch(cmd, GetPlayerStateCmd(m.client))
+}
+
+// notifyObservers tells the observers about the last player state.
+func (m TabModel) notifyObservers() {
+	if len(m.observers) == 0 {
+		return
+	}
+	np := newNowPlaying(m.playerState, m.playingEpisode)
+	for _, o := range m.observers {
+		o.PlayerChanged(np)
+	}
+}
+
+func isEpisode(item *spotify.FullTrack) bool {
+	return item != nil && item.Type == "episode"
+}
+
 func playOnDevice(m TabModel) (tea.Model, tea.Cmd) {
-	m.currentDevice = &m.devices[m.listView.list.Index()]
+	selected := m.listView.Index()
+	if selected < 0 || selected >= len(m.devices) {
+		return m, nil
+	}
+	m.currentDevice = &m.devices[selected]
 	m.deviceMode = false
 	m.depth = TOP
 	if m.currentlyPlaying != nil && m.currentlyPlaying.Playing {
@@ -211,36 +547,83 @@ func playOnDevice(m TabModel) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func execTxtCommand(m TabModel) (tea.Model, tea.Cmd) {
-	txtCmd := m.textInput.textInput.Value()
-	m.textMode = NONE
-	m.textInput = NewTextModel()
+func togglePlayback(m TabModel) (tea.Model, tea.Cmd) {
+	if m.progress.IsPlaying {
+		return m, PausePlaybackCmd(m.client)
+	}
+	return resumePlayback(m)
+}
 
-	switch txtCmd {
-	case "play":
-		if m.currentlyPlaying == nil {
-			return m, nil
+func resumePlayback(m TabModel) (tea.Model, tea.Cmd) {
+	if m.currentlyPlaying == nil {
+		return m, nil
+	}
+	if m.progress.IsPlaying {
+		return m, nil
+	}
+
+	var opt *spotify.PlayOptions
+	if m.currentDevice != nil {
+		opt = &spotify.PlayOptions{
+			DeviceID:   &m.currentDevice.ID,
+			URIs:       []spotify.URI{m.currentlyPlaying.Item.URI},
+			PositionMs: m.currentlyPlaying.Progress,
 		}
-		if m.progress.IsPlaying {
-			return m, nil
+
+	} else {
+		opt = &spotify.PlayOptions{
+			URIs:       []spotify.URI{m.currentlyPlaying.Item.URI},
+			PositionMs: m.currentlyPlaying.Progress,
 		}
+	}
+	return m, StartPlaybackCmd(m.client, opt)
+}
 
-		var opt *spotify.PlayOptions
-		if m.devices != nil {
-			opt = &spotify.PlayOptions{
-				DeviceID:   &m.currentDevice.ID,
-				URIs:       []spotify.URI{m.currentlyPlaying.Item.URI},
-				PositionMs: m.currentlyPlaying.Progress,
-			}
+// seekBy moves the playback position by delta, staying inside the track.
+func seekBy(m TabModel, delta time.Duration) (tea.Model, tea.Cmd) {
+	if m.currentlyPlaying == nil || m.currentlyPlaying.Item == nil || m.currentlyPlaying.Item.Duration <= 0 {
+		return m, nil
+	}
+	pos := m.progress.PositionMs() + int(delta.Milliseconds())
+	return m, SeekCmd(m.client, min(max(pos, 0), m.currentlyPlaying.Item.Duration))
+}
 
-		} else {
-			opt = &spotify.PlayOptions{
-				URIs:       []spotify.URI{m.currentlyPlaying.Item.URI},
-				PositionMs: m.currentlyPlaying.Progress,
-			}
-		}
-		return m, StartPlaybackCmd(m.client, opt)
+func changeVolume(m TabModel, delta int) (tea.Model, tea.Cmd) {
+	if m.playerState == nil {
+		return m, nil
+	}
+	return m, VolumeCmd(m.client, min(max(m.playerState.Device.Volume+delta, 0), 100))
+}
 
+func toggleShuffle(m TabModel) (tea.Model, tea.Cmd) {
+	if m.playerState == nil {
+		return m, nil
+	}
+	return m, ShuffleCmd(m.client, !m.playerState.ShuffleState)
+}
+
+// cycleRepeat switches between repeating nothing, the context and the track.
+func cycleRepeat(m TabModel) (tea.Model, tea.Cmd) {
+	if m.playerState == nil {
+		return m, nil
+	}
+	next := map[string]string{"off": "context", "context": "track", "track": "off"}
+	state, ok := next[m.playerState.RepeatState]
+	if !ok {
+		state = "off"
+	}
+	return m, RepeatCmd(m.client, state)
+}
+
+func execTxtCommand(m TabModel) (tea.Model, tea.Cmd) {
+	txtCmd, arg, _ := strings.Cut(strings.TrimSpace(m.textInput.textInput.Value()), " ")
+	arg = strings.TrimSpace(arg)
+	m.textMode = NONE
+	m.textInput = NewTextModel()
+
+	switch txtCmd {
+	case "play":
+		return resumePlayback(m)
 	case "pause":
 		return m, PausePlaybackCmd(m.client)
 	case "next":
@@ -249,16 +632,162 @@ func execTxtCommand(m TabModel) (tea.Model, tea.Cmd) {
 		return m, PreviousPlaybackCmd(m.client)
 	case "device":
 		return m, GetAvailableDevicesCmd(m.client)
+	case "queue":
+		return m, GetQueueCmd(m.client)
+	case "seek":
+		return seekCommand(m, arg)
+	case "vol", "volume":
+		return volumeCommand(m, arg)
+	case "shuffle":
+		switch arg {
+		case "":
+			return toggleShuffle(m)
+		case "on", "off":
+			return m, ShuffleCmd(m.client, arg == "on")
+		default:
+			return m, errCmd(fmt.Errorf("shuffle: expected on or off, got %q", arg))
+		}
+	case "repeat":
+		switch arg {
+		case "":
+			return cycleRepeat(m)
+		case "off", "context", "track":
+			return m, RepeatCmd(m.client, arg)
+		default:
+			return m, errCmd(fmt.Errorf("repeat: expected off, context or track, got %q", arg))
+		}
+	case "search":
+		if arg == "" {
+			return m, nil
+		}
+		return startSearch(m, arg)
+	case "profile":
+		return switchProfile(m, arg)
 
 	default:
 		return m, nil
 	}
 }
 
+// startSearch opens the search tab and looks query up.
+func startSearch(m TabModel, query string) (tea.Model, tea.Cmd) {
+	m.activeTab = SEARCH
+	m.depth = TOP
+	m.closeDetail()
+	m.deviceMode = false
+	m.queueMode = false
+	m.search = newSearchResults(query)
+	m.searchRequest++
+	m.tabContents[SEARCH] = m.newListModel([]list.Item{item{title: loading}})
+	m.tabContents[SEARCH].Fetching = true
+	return m, SearchCmd(m.client, query, 0, m.searchRequest)
+}
+
+// switchProfile logs in as the profile called name and reloads the library
+// for its account.
+func switchProfile(m TabModel, name string) (tea.Model, tea.Cmd) {
+	if name == "" {
+		return m, errCmd(fmt.Errorf("profile: expected a name, current profile is %s", m.profile.Name))
+	}
+	if m.offline {
+		return m, errCmd(errors.New("profile: there are no accounts in demo mode"))
+	}
+	p, err := lookupProfile(m.profiles, name)
+	if err != nil {
+		return m, errCmd(fmt.Errorf("profile: %w", err))
+	}
+
+	m.closeDetail()
+	n := TabModel{
+		tabs:      m.tabs,
+		depth:     TOP,
+		detail:    NODETAIL,
+		textInput: NewTextModel(),
+		help:      m.help,
+		styles:    m.styles,
+		layout:    m.layout,
+		login:     m.login,
+		tokens:    m.tokens,
+		profile:   p,
+		profiles:  m.profiles,
+		cache:     newLibraryCache(p.Name),
+		// Replies for the old account must not match a new request.
+		detailRequest: m.detailRequest,
+		searchRequest: m.searchRequest,
+		pollID:        m.pollID,
+		player:        m.player,
+		// MPRIS and the control socket serve whichever account is in use.
+		observers: m.observers,
+	}
+	n.resetLists()
+	// Nothing is known to be playing until the new account logs in.
+	n.notifyObservers()
+	return n, loginCmd(n.login, n.tokens, n.profile)
+}
+
+// seekCommand handles ":seek 1:23" and relative forms such as ":seek +10".
+func seekCommand(m TabModel, arg string) (tea.Model, tea.Cmd) {
+	pos, err := parsePosition(strings.TrimLeft(arg, "+-"))
+	if err != nil {
+		return m, errCmd(fmt.Errorf("seek: %w", err))
+	}
+	switch {
+	case strings.HasPrefix(arg, "+"):
+		return seekBy(m, pos)
+	case strings.HasPrefix(arg, "-"):
+		return seekBy(m, -pos)
+	}
+	if m.currentlyPlaying == nil || m.currentlyPlaying.Item == nil {
+		return m, nil
+	}
+	return m, SeekCmd(m.client, min(int(pos.Milliseconds()), m.currentlyPlaying.Item.Duration))
+}
+
+// volumeCommand handles ":vol 50" and relative forms such as ":vol -10".
+func volumeCommand(m TabModel, arg string) (tea.Model, tea.Cmd) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return m, errCmd(fmt.Errorf("volume: expected a percentage, got %q", arg))
+	}
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		return changeVolume(m, n)
+	}
+	return m, VolumeCmd(m.client, min(max(n, 0), 100))
+}
+
+// parsePosition parses seconds ("83"), "m:ss" or "h:mm:ss".
+func parsePosition(s string) (time.Duration, error) {
+	var d time.Duration
+	fields := strings.Split(s, ":")
+	if len(fields) > 3 {
+		return 0, fmt.Errorf("invalid position %q", s)
+	}
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid position %q", s)
+		}
+		d = d*60 + time.Duration(n)*time.Second
+	}
+	return d, nil
+}
+
+func errCmd(err error) tea.Cmd {
+	return func() tea.Msg {
+		return ErrMsg{Err: err}
+	}
+}
+
 func playTrack(m TabModel) (tea.Model, tea.Cmd) {
-	selected := m.listView.list.Index()
-	switch m.activeTab {
+	selected := m.listView.Index()
+	if selected < 0 {
+		return m, nil
+	}
+	switch m.detail {
 	case PLAYLIST:
+		if m.selectedPlaylist == nil || selected >= len(m.selectedPlaylist.Tracks.Tracks) {
+			return m, nil
+		}
 		return m, StartPlaybackCmd(m.client,
 			&spotify.PlayOptions{
 				PlaybackContext: &m.selectedPlaylist.URI,
@@ -269,6 +798,9 @@ func playTrack(m TabModel) (tea.Model, tea.Cmd) {
 		)
 
 	case ALBUM:
+		if m.selectedAlbum == nil || selected >= len(m.selectedAlbum.Tracks.Tracks) {
+			return m, nil
+		}
 		return m, StartPlaybackCmd(m.client,
 			&spotify.PlayOptions{
 				PlaybackContext: &m.selectedAlbum.URI,
@@ -279,36 +811,188 @@ func playTrack(m TabModel) (tea.Model, tea.Cmd) {
 		)
 
 	case PODCAST:
-		return m, StartPlaybackCmd(m.client,
-			&spotify.PlayOptions{
-				URIs: []spotify.URI{m.episodes[selected].URI},
-			},
-		)
+		if m.selectedShow == nil || selected >= len(m.selectedShow.Episodes.Episodes) {
+			return m, nil
+		}
+		episode := m.selectedShow.Episodes.Episodes[selected]
+		opts := &spotify.PlayOptions{
+			URIs: []spotify.URI{episode.URI},
+		}
+		if !episode.ResumePoint.FullyPlayed {
+			opts.PositionMs = episode.ResumePoint.ResumePositionMs
+		}
+		return m, StartPlaybackCmd(m.client, opts)
 	default:
 		return m, nil
 	}
 }
 
+// enqueueTrack adds the selected track or episode to the playback queue.
+func enqueueTrack(m TabModel) (tea.Model, tea.Cmd) {
+	selected := m.listView.Index()
+	if selected < 0 {
+		return m, nil
+	}
+	var uri spotify.URI
+	switch m.detail {
+	case PLAYLIST:
+		if m.selectedPlaylist == nil || selected >= len(m.selectedPlaylist.Tracks.Tracks) {
+			return m, nil
+		}
+		uri = m.selectedPlaylist.Tracks.Tracks[selected].Track.URI
+	case ALBUM:
+		if m.selectedAlbum == nil || selected >= len(m.selectedAlbum.Tracks.Tracks) {
+			return m, nil
+		}
+		uri = m.selectedAlbum.Tracks.Tracks[selected].URI
+	case PODCAST:
+		if m.selectedShow == nil || selected >= len(m.selectedShow.Episodes.Episodes) {
+			return m, nil
+		}
+		uri = m.selectedShow.Episodes.Episodes[selected].URI
+	default:
+		return m, nil
+	}
+	return m, QueueItemCmd(m.client, uri)
+}
+
 func listUpdate(m TabModel, msg tea.Msg) (tea.Model, tea.Cmd) {
+	// cache refreshes or saves the album or playlist that was opened.
+	var cache tea.Cmd
 
 	switch msg := msg.(type) {
 	case AlbumDetailMsg:
+		if m.detailCached {
+			if m.detail != ALBUM || m.selectedAlbum.ID != msg.Album.ID {
+				return m, nil
+			}
+			return refreshAlbum(m, msg.Album)
+		}
+		m.detail = ALBUM
 		m.selectedAlbum = msg.Album
-		m.listView = NewListModel(
+		m.listView = m.newListModel(
 			albumTracksToItemList(msg.Album.Tracks.Tracks),
 			WithTitle(msg.Album.Name+" ("+msg.Album.Artists[0].Name+")"),
+			WithColumns(albumColumns...),
+		)
+		m.detailCached = msg.Cached
+		// No more tracks are loaded until the cached album is replaced.
+		m.listView.Fetching = msg.Cached
+		if msg.Cached {
+			cache = m.detailCmd(GetAlbumCmd(m.detailCtx, m.client, msg.Album.ID))
+		} else {
+			cache = m.saveCompleteAlbum()
+		}
+
+	case AlbumTracksMsg:
+		if r := m.refreshingAlbum; r != nil && r.ID == msg.AlbumID {
+			if msg.Tracks.Offset != len(r.Tracks.Tracks) {
+				return m, nil
+			}
+			r.Tracks.Tracks = append(r.Tracks.Tracks, msg.Tracks.Tracks...)
+			r.Tracks.Total = msg.Tracks.Total
+			// Do not ask for pages that the Web API does not have.
+			if len(msg.Tracks.Tracks) == 0 {
+				r.Tracks.Total = len(r.Tracks.Tracks)
+			}
+			return refreshAlbum(m, r)
+		}
+		if m.detail != ALBUM || m.selectedAlbum == nil || m.selectedAlbum.ID != msg.AlbumID {
+			return m, nil
+		}
+		tracks := &m.selectedAlbum.Tracks
+		// A page for a copy that was replaced in the meantime.
+		if msg.Tracks.Offset != len(tracks.Tracks) {
+			return m, nil
+		}
+		tracks.Tracks = append(tracks.Tracks, msg.Tracks.Tracks...)
+		tracks.Total = msg.Tracks.Total
+		m.listView.Fetching = false
+		return m, tea.Batch(
+			m.listView.SetItems(albumTracksToItemList(tracks.Tracks)),
+			m.saveCompleteAlbum(),
 		)
 
 	case ShowDetailMsg:
-		m.episodes = msg.Show.Episodes.Episodes
-		m.listView = NewListModel(episodesToItemList(m.episodes),
+		m.detail = PODCAST
+		m.selectedShow = msg.Show
+		m.listView = m.newListModel(episodesToItemList(msg.Show.Episodes.Episodes),
 			WithTitle(msg.Show.Name),
+			WithColumns(episodeColumns...),
 		)
 
+	case ShowEpisodesMsg:
+		if m.detail != PODCAST || m.selectedShow == nil || m.selectedShow.ID != msg.ShowID {
+			return m, nil
+		}
+		m.selectedShow.Episodes.Episodes = append(m.selectedShow.Episodes.Episodes, msg.Episodes.Episodes...)
+		m.selectedShow.Episodes.Total = msg.Episodes.Total
+		m.listView.Fetching = false
+		return m, m.listView.SetItems(episodesToItemList(m.selectedShow.Episodes.Episodes))
+
+	case LoadMoreMsg:
+		// Passing it on to the list would ask for more again.
+		if m.deviceMode || m.queueMode {
+			return m, nil
+		}
+		switch m.detail {
+		case PODCAST:
+			return loadMoreEpisodes(m)
+		case ALBUM:
+			return loadMoreAlbumTracks(m)
+		case PLAYLIST:
+			return loadMorePlaylistTracks(m)
+		}
+		return m, nil
+
 	case PlaylistDetailMsg:
+		if m.detailCached {
+			if m.detail != PLAYLIST || m.selectedPlaylist.ID != msg.Playlist.ID {
+				return m, nil
+			}
+			return refreshPlaylist(m, msg.Playlist)
+		}
+		m.detail = PLAYLIST
 		m.selectedPlaylist = msg.Playlist
-		m.listView = NewListModel(playlistTracksToItemList(msg.Playlist.Tracks.Tracks),
+		m.listView = m.newListModel(playlistTracksToItemList(msg.Playlist.Tracks.Tracks),
 			WithTitle(msg.Playlist.Name),
+			WithColumns(playlistColumns...),
+		)
+		// An unchanged snapshot ID means that the cached copy is current.
+		m.detailCached = msg.Cached && (msg.Playlist.SnapshotID == "" || msg.Playlist.SnapshotID != m.playlistSnapshot(msg.Playlist.ID))
+		m.listView.Fetching = m.detailCached
+		switch {
+		case m.detailCached:
+			cache = m.detailCmd(GetPlaylistCmd(m.detailCtx, m.client, msg.Playlist.ID))
+		case !msg.Cached:
+			cache = m.saveCompletePlaylist()
+		}
+
+	case PlaylistTracksMsg:
+		if r := m.refreshingPlaylist; r != nil && r.ID == msg.PlaylistID {
+			if msg.Tracks.Offset != len(r.Tracks.Tracks) {
+				return m, nil
+			}
+			r.Tracks.Tracks = append(r.Tracks.Tracks, msg.Tracks.Tracks...)
+			r.Tracks.Total = msg.Tracks.Total
+			if len(msg.Tracks.Tracks) == 0 {
+				r.Tracks.Total = len(r.Tracks.Tracks)
+			}
+			return refreshPlaylist(m, r)
+		}
+		if m.detail != PLAYLIST || m.selectedPlaylist == nil || m.selectedPlaylist.ID != msg.PlaylistID {
+			return m, nil
+		}
+		tracks := &m.selectedPlaylist.Tracks
+		if msg.Tracks.Offset != len(tracks.Tracks) {
+			return m, nil
+		}
+		tracks.Tracks = append(tracks.Tracks, msg.Tracks.Tracks...)
+		tracks.Total = msg.Tracks.Total
+		m.listView.Fetching = false
+		return m, tea.Batch(
+			m.listView.SetItems(playlistTracksToItemList(tracks.Tracks)),
+			m.saveCompletePlaylist(),
 		)
 
 	case UpdateDepthMsg:
@@ -316,12 +1000,112 @@ func listUpdate(m TabModel, msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.depth = min(m.depth+msg.delta, TRACKLIST)
 		} else {
 			m.depth = max(m.depth+msg.delta, TOP)
+			m.closeDetail()
+			m.deviceMode = false
+			m.queueMode = false
 		}
 	}
 
-	newListModel, cmd := m.listView.UpdateList(msg, m.depth)
+	newListModel, cmd := m.listView.UpdateList(msg, m.help.KeyMap)
 	m.listView = newListModel
-	return m, cmd
+	return m, tea.Batch(cache, cmd)
+}
+
+// refreshAlbum replaces the cached album being shown with album, a copy
+// fetched from the Web API, once all its tracks are loaded. Until then the
+// cached tracks stay, so that the list does not shrink to the first page.
+func refreshAlbum(m TabModel, album *spotify.FullAlbum) (tea.Model, tea.Cmd) {
+	if tracks := album.Tracks; len(tracks.Tracks) < tracks.Total {
+		m.refreshingAlbum = album
+		return m, m.detailCmd(FetchAlbumTracksCmd(m.detailCtx, m.client, album.ID, len(tracks.Tracks)))
+	}
+	m.refreshingAlbum = nil
+	m.detailCached = false
+	m.selectedAlbum = album
+	m.listView.Fetching = false
+	return m, tea.Batch(
+		m.listView.SetItems(albumTracksToItemList(album.Tracks.Tracks)),
+		m.saveCompleteAlbum(),
+	)
+}
+
+// refreshPlaylist replaces the cached playlist being shown with playlist, a
+// copy fetched from the Web API, once all its tracks are loaded.
+func refreshPlaylist(m TabModel, playlist *spotify.FullPlaylist) (tea.Model, tea.Cmd) {
+	if tracks := playlist.Tracks; len(tracks.Tracks) < tracks.Total {
+		m.refreshingPlaylist = playlist
+		return m, m.detailCmd(FetchPlaylistTracksCmd(m.detailCtx, m.client, playlist.ID, len(tracks.Tracks)))
+	}
+	m.refreshingPlaylist = nil
+	m.detailCached = false
+	m.selectedPlaylist = playlist
+	m.listView.Fetching = false
+	return m, tea.Batch(
+		m.listView.SetItems(playlistTracksToItemList(playlist.Tracks.Tracks)),
+		m.saveCompletePlaylist(),
+	)
+}
+
+// playlistSnapshot returns the snapshot ID of a playlist in the library.
+func (m TabModel) playlistSnapshot(id spotify.ID) string {
+	if m.playlists == nil {
+		return ""
+	}
+	for _, p := range m.playlists.Playlists {
+		if p.ID == id {
+			return p.SnapshotID
+		}
+	}
+	return ""
+}
+
+// loadMoreAlbumTracks fetches the next page of the open album's tracks.
+func loadMoreAlbumTracks(m TabModel) (tea.Model, tea.Cmd) {
+	tracks := m.selectedAlbum.Tracks
+	if m.listView.Fetching || len(tracks.Tracks) >= tracks.Total {
+		return m, nil
+	}
+	m.listView.Fetching = true
+	return m, m.detailCmd(FetchAlbumTracksCmd(m.detailCtx, m.client, m.selectedAlbum.ID, len(tracks.Tracks)))
+}
+
+// loadMorePlaylistTracks fetches the next page of the open playlist's
+// tracks.
+func loadMorePlaylistTracks(m TabModel) (tea.Model, tea.Cmd) {
+	tracks := m.selectedPlaylist.Tracks
+	if m.listView.Fetching || len(tracks.Tracks) >= tracks.Total {
+		return m, nil
+	}
+	m.listView.Fetching = true
+	return m, m.detailCmd(FetchPlaylistTracksCmd(m.detailCtx, m.client, m.selectedPlaylist.ID, len(tracks.Tracks)))
+}
+
+// saveCompleteAlbum caches the open album once all its tracks are loaded,
+// so that opening it again does not page through them again.
+func (m TabModel) saveCompleteAlbum() tea.Cmd {
+	if len(m.selectedAlbum.Tracks.Tracks) < m.selectedAlbum.Tracks.Total {
+		return nil
+	}
+	return m.cache.saveCmd(albumCache(m.selectedAlbum.ID), m.selectedAlbum)
+}
+
+// saveCompletePlaylist caches the open playlist once all its tracks are
+// loaded.
+func (m TabModel) saveCompletePlaylist() tea.Cmd {
+	if len(m.selectedPlaylist.Tracks.Tracks) < m.selectedPlaylist.Tracks.Total {
+		return nil
+	}
+	return m.cache.saveCmd(playlistCache(m.selectedPlaylist.ID), m.selectedPlaylist)
+}
+
+// loadMoreEpisodes fetches the next page of the open show's episodes.
+func loadMoreEpisodes(m TabModel) (tea.Model, tea.Cmd) {
+	episodes := m.selectedShow.Episodes
+	if m.listView.Fetching || len(episodes.Episodes) >= episodes.Total {
+		return m, nil
+	}
+	m.listView.Fetching = true
+	return m, m.detailCmd(FetchShowEpisodesCmd(m.detailCtx, m.client, m.selectedShow.ID, len(episodes.Episodes)))
 }
 
 func tabUpdate(msg tea.Msg, m TabModel) (tea.Model, tea.Cmd) {
@@ -329,123 +1113,310 @@ func tabUpdate(msg tea.Msg, m TabModel) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch keypress := msg.String(); keypress {
-		case "ctrl+c", "q":
+		keys := m.help.KeyMap
+		switch {
+		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
-		case "l", "n", "tab", "right":
+		case key.Matches(msg, keys.NextTab):
 			m.activeTab = min(m.activeTab+1, len(m.tabs)-1)
 			return m, nil
-		case "h", "p", "shift+tab", "left":
+		case key.Matches(msg, keys.PrevTab):
 			m.activeTab = max(m.activeTab-1, 0)
 			return m, nil
-		case "j", "down", "k", "up":
-			if m.playlists == nil || m.albums == nil || m.shows == nil {
+		case key.Matches(msg, keys.Up, keys.Down, keys.Filter):
+			if !m.tabLoaded() {
 				return m, nil
 			}
 			var newListModel ListModel
-			newListModel, cmd = m.tabContents[m.activeTab].UpdateList(msg, m.depth)
+			newListModel, cmd = m.tabContents[m.activeTab].UpdateList(msg, keys)
 			m.tabContents[m.activeTab] = newListModel
 
-		case "enter", " ":
-			if m.playlists == nil || m.albums == nil || m.shows == nil {
+		case key.Matches(msg, keys.Select):
+			if !m.tabLoaded() {
 				return m, nil
 			}
+			if m.activeTab == SEARCH {
+				return openSearchEntry(m)
+			}
 			return getTracks(m)
 		}
 
+	case LoadMoreMsg:
+		switch m.activeTab {
+		case PLAYLIST:
+			if m.tabContents[PLAYLIST].Fetching {
+				return m, nil
+			}
+			m.tabContents[PLAYLIST].Fetching = true
+			return m, FetchPlaylistsCmd(m.client, m.playlists.Offset+len(m.playlists.Playlists))
+		case ALBUM:
+			if m.tabContents[ALBUM].Fetching {
+				return m, nil
+			}
+			m.tabContents[ALBUM].Fetching = true
+			return m, FetchAlbumsCmd(m.client, m.albums.Offset+len(m.albums.Albums))
+		case PODCAST:
+			if m.tabContents[PODCAST].Fetching {
+				return m, nil
+			}
+			m.tabContents[PODCAST].Fetching = true
+			return m, FetchShowsCmd(m.client, m.shows.Offset+len(m.shows.Shows))
+		case SEARCH:
+			if m.search == nil || !m.search.more || m.tabContents[SEARCH].Fetching {
+				return m, nil
+			}
+			m.tabContents[SEARCH].Fetching = true
+			return m, SearchCmd(m.client, m.search.query, m.search.offset, m.searchRequest)
+
+		}
+	}
+	return m, cmd
+}
+
+// libraryUpdate adds a page of the library to its tab. Pages arrive
+// whatever is on screen, and a revalidated first page that was dropped
+// would leave the tab without paging.
+func libraryUpdate(m TabModel, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
 	case AlbumMsg:
-		if m.albums == nil {
+		switch {
+		case m.albums == nil:
 			m.albums = msg.Albums
-			m.tabContents[ALBUM] = NewListModel(albumToItemList(msg.Albums))
-		} else {
+			m.tabContents[ALBUM] = m.newListModel(albumToItemList(msg.Albums))
+		case msg.Cached:
+			// The Web API answered first.
+			return m, nil
+		case msg.Albums.Offset == 0:
+			m.albums = msg.Albums
+			cmd = m.tabContents[ALBUM].refresh(albumToItemList(m.albums))
+		default:
 			m.albums.Offset = msg.Albums.Offset
 			newAlbums := append(m.albums.Albums, msg.Albums.Albums...)
 			m.albums.Albums = newAlbums
 
-			newListModel := NewListModel(albumToItemList(m.albums))
-			newListModel.list.Select(m.tabContents[ALBUM].list.Index())
-			m.tabContents[ALBUM] = newListModel
+			cmd = m.tabContents[ALBUM].SetItems(albumToItemList(m.albums))
+		}
+		// No more pages are loaded until the cached page is replaced.
+		m.tabContents[ALBUM].Fetching = msg.Cached
+		if !msg.Cached && msg.Albums.Offset == 0 {
+			cmd = tea.Batch(cmd, m.cache.saveCmd(albumsCache, msg.Albums))
 		}
-		m.tabContents[ALBUM].Fetching = false
 
 	case PlaylistMsg:
-		if m.playlists == nil {
+		switch {
+		case m.playlists == nil:
 			m.playlists = msg.Playlists
-			m.tabContents[PLAYLIST] = NewListModel(playlistsToItemList(msg.Playlists))
-		} else {
+			m.tabContents[PLAYLIST] = m.newListModel(playlistsToItemList(msg.Playlists))
+		case msg.Cached:
+			return m, nil
+		case msg.Playlists.Offset == 0:
+			m.playlists = msg.Playlists
+			cmd = m.tabContents[PLAYLIST].refresh(playlistsToItemList(m.playlists))
+		default:
 			m.playlists.Offset = msg.Playlists.Offset
 			newPlaylists := append(m.playlists.Playlists, msg.Playlists.Playlists...)
 			m.playlists.Playlists = newPlaylists
 
-			newListModel := NewListModel(playlistsToItemList(m.playlists))
-			newListModel.list.Select(m.tabContents[PLAYLIST].list.Index())
-			m.tabContents[PLAYLIST] = newListModel
+			cmd = m.tabContents[PLAYLIST].SetItems(playlistsToItemList(m.playlists))
+		}
+		m.tabContents[PLAYLIST].Fetching = msg.Cached
+		if !msg.Cached && msg.Playlists.Offset == 0 {
+			cmd = tea.Batch(cmd, m.cache.saveCmd(playlistsCache, msg.Playlists))
 		}
-		m.tabContents[PLAYLIST].Fetching = false
 
 	case ShowMsg:
-		if m.shows == nil {
+		switch {
+		case m.shows == nil:
 			m.shows = msg.Shows
-			m.tabContents[PODCAST] = NewListModel(showsToItemList(msg.Shows))
-		} else {
+			m.tabContents[PODCAST] = m.newListModel(showsToItemList(msg.Shows))
+		case msg.Cached:
+			return m, nil
+		case msg.Shows.Offset == 0:
+			m.shows = msg.Shows
+			cmd = m.tabContents[PODCAST].refresh(showsToItemList(m.shows))
+		default:
 			m.shows.Offset = msg.Shows.Offset
 			newShows := append(m.shows.Shows, msg.Shows.Shows...)
 			m.shows.Shows = newShows
 
-			newListModel := NewListModel(showsToItemList(m.shows))
-			newListModel.list.Select(m.tabContents[PODCAST].list.Index())
-			m.tabContents[PODCAST] = newListModel
+			cmd = m.tabContents[PODCAST].SetItems(showsToItemList(m.shows))
 		}
-		m.tabContents[PODCAST].Fetching = false
+		m.tabContents[PODCAST].Fetching = msg.Cached
+		if !msg.Cached && msg.Shows.Offset == 0 {
+			cmd = tea.Batch(cmd, m.cache.saveCmd(showsCache, msg.Shows))
+		}
+	}
+	return m, cmd
+}
 
-	case LoadMoreMsg:
-		switch m.activeTab {
-		case PLAYLIST:
-			if m.tabContents[PLAYLIST].Fetching {
-				return m, nil
-			}
-			m.tabContents[PLAYLIST].Fetching = true
-			return m, FetchPlaylistsCmd(m.client, spotify.Offset(m.playlists.Offset+len(m.playlists.Playlists)))
-		case ALBUM:
-			if m.tabContents[ALBUM].Fetching {
-				return m, nil
-			}
-			m.tabContents[ALBUM].Fetching = true
-			return m, FetchAlbumsCmd(m.client, spotify.Offset(m.albums.Offset+len(m.albums.Albums)))
-		case PODCAST:
-			if m.tabContents[PODCAST].Fetching {
-				return m, nil
-			}
-			m.tabContents[PODCAST].Fetching = true
-			return m, FetchShowsCmd(m.client, spotify.Offset(m.shows.Offset+len(m.shows.Shows)))
+// searchUpdate adds a page of results to the search tab. Like library
+// pages, they arrive whatever is on screen.
+func searchUpdate(m TabModel, msg SearchMsg) (tea.Model, tea.Cmd) {
+	// The page belongs to an earlier search.
+	if m.search == nil || msg.request != m.searchRequest {
+		return m, nil
+	}
+	var selectedURI spotify.URI
+	if entry, ok := m.selectedSearchEntry(); ok {
+		selectedURI = entry.uri
+	}
+	m.search.merge(msg.Result)
 
+	newListModel := m.newListModel(m.search.items(), WithTitle("Search: "+msg.Query))
+	for i, e := range m.search.entries {
+		if selectedURI != "" && e.uri == selectedURI {
+			newListModel.list.Select(i)
 		}
 	}
-	return m, cmd
+	m.tabContents[SEARCH] = newListModel
+	m.tabContents[SEARCH].Fetching = false
+	return m, nil
+}
+
+// tabLoaded reports whether the active tab has content to navigate.
+func (m TabModel) tabLoaded() bool {
+	switch m.activeTab {
+	case PLAYLIST:
+		return m.playlists != nil
+	case ALBUM:
+		return m.albums != nil
+	case PODCAST:
+		return m.shows != nil
+	case SEARCH:
+		return m.search != nil && len(m.search.entries) > 0
+	default:
+		return false
+	}
+}
+
+func (m TabModel) selectedSearchEntry() (searchEntry, bool) {
+	if m.search == nil {
+		return searchEntry{}, false
+	}
+	selected := m.tabContents[SEARCH].Index()
+	if selected < 0 || selected >= len(m.search.entries) {
+		return searchEntry{}, false
+	}
+	return m.search.entries[selected], true
+}
+
+// openSearchEntry drills into an album, playlist or show, or starts
+// playback of a track or artist.
+func openSearchEntry(m TabModel) (tea.Model, tea.Cmd) {
+	entry, ok := m.selectedSearchEntry()
+	if !ok {
+		return m, nil
+	}
+
+	switch entry.kind {
+	case searchTrack:
+		return m, StartPlaybackCmd(m.client,
+			&spotify.PlayOptions{
+				URIs: []spotify.URI{entry.uri},
+			},
+		)
+	case searchArtist:
+		return m, StartPlaybackCmd(m.client,
+			&spotify.PlayOptions{
+				PlaybackContext: &entry.uri,
+			},
+		)
+	case searchAlbum:
+		m.openDetail()
+		return m, m.detailCmd(m.cache.getAlbumCmd(m.detailCtx, m.client, entry.id))
+	case searchPlaylist:
+		m.openDetail()
+		return m, m.detailCmd(m.cache.getPlaylistCmd(m.detailCtx, m.client, entry.id))
+	case searchShow:
+		m.openDetail()
+		return m, m.detailCmd(GetShowCmd(m.detailCtx, m.client, entry.id))
+	default:
+		return m, nil
+	}
 }
 
 func getTracks(m TabModel) (tea.Model, tea.Cmd) {
 
-	m.depth = TRACKLIST
-	m.listView = NewListModel([]list.Item{item(loading)})
+	selected := m.tabContents[m.activeTab].Index()
+	if selected < 0 {
+		return m, nil
+	}
+
+	m.openDetail()
 
-	selected := m.tabContents[m.activeTab].list.Index()
 	switch m.activeTab {
 	case PLAYLIST:
-		return m, GetPlaylistCmd(m.client, m.playlists.Playlists[selected].ID)
+		return m, m.detailCmd(m.cache.getPlaylistCmd(m.detailCtx, m.client, m.playlists.Playlists[selected].ID))
 	case ALBUM:
-		return m, GetAlbumCmd(m.client, m.albums.Albums[selected].ID)
+		return m, m.detailCmd(m.cache.getAlbumCmd(m.detailCtx, m.client, m.albums.Albums[selected].ID))
 	case PODCAST:
-		return m, GetShowCmd(m.client, m.shows.Shows[selected].ID)
+		return m, m.detailCmd(GetShowCmd(m.detailCtx, m.client, m.shows.Shows[selected].ID))
 	default:
 		return m, nil
 	}
 }
 
+// detailMsg is the reply to a request made for a tracklist. Replies for a
+// tracklist that has been left are dropped.
+type detailMsg struct {
+	request int
+	msg     tea.Msg
+}
+
+// openDetail shows an empty tracklist until it is loaded, cancelling the
+// requests made for the previous one.
+func (m *TabModel) openDetail() {
+	m.closeDetail()
+	m.detailCtx, m.cancelDetail = context.WithCancel(context.Background())
+	m.detail = NODETAIL
+	m.selectedAlbum = nil
+	m.selectedPlaylist = nil
+	m.selectedShow = nil
+	m.detailCached = false
+	m.refreshingAlbum = nil
+	m.refreshingPlaylist = nil
+	m.depth = TRACKLIST
+	m.listView = m.newListModel([]list.Item{item{title: loading}})
+}
+
+// closeDetail cancels the requests of the tracklist being left.
+func (m *TabModel) closeDetail() {
+	if m.cancelDetail != nil {
+		m.cancelDetail()
+	}
+	m.detailRequest++
+}
+
+// detailCmd tags the reply of cmd, a request for the tracklist being shown.
+func (m TabModel) detailCmd(cmd tea.Cmd) tea.Cmd {
+	request := m.detailRequest
+	return func() tea.Msg {
+		return detailMsg{request: request, msg: cmd()}
+	}
+}
+
 func playerDeviceToItemList(devices []spotify.PlayerDevice) []list.Item {
 	var itemList []list.Item
 	for _, d := range devices {
-		itemList = append(itemList, item(d.Name))
+		itemList = append(itemList, item{title: d.Name})
+	}
+	return itemList
+}
+
+func queueToItemList(queue *spotify.Queue) []list.Item {
+	var itemList []list.Item
+	if queue.CurrentlyPlaying.URI != "" {
+		playing := fullTrackToItem(queue.CurrentlyPlaying)
+		playing.title = "▶ " + playing.title
+		itemList = append(itemList, playing)
+	}
+	for _, t := range queue.Items {
+		itemList = append(itemList, fullTrackToItem(t))
+	}
+	if len(itemList) == 0 {
+		itemList = append(itemList, item{title: "Queue is empty"})
 	}
 	return itemList
 }
@@ -453,7 +1424,9 @@ func playerDeviceToItemList(devices []spotify.PlayerDevice) []list.Item {
 func playlistTracksToItemList(tracks []spotify.PlaylistTrack) []list.Item {
 	var itemList []list.Item
 	for _, t := range tracks {
-		itemList = append(itemList, item(t.Track.Name))
+		it := fullTrackToItem(t.Track)
+		it.addedAt, _ = time.Parse(time.RFC3339, t.AddedAt)
+		itemList = append(itemList, it)
 	}
 	return itemList
 }
@@ -461,7 +1434,17 @@ func playlistTracksToItemList(tracks []spotify.PlaylistTrack) []list.Item {
 func episodesToItemList(episodes []spotify.EpisodePage) []list.Item {
 	var itemList []list.Item
 	for _, e := range episodes {
-		itemList = append(itemList, item(e.Name))
+		it := item{
+			title:    e.Name,
+			duration: time.Duration(e.Duration_ms) * time.Millisecond,
+			explicit: e.Explicit,
+			resume:   time.Duration(e.ResumePoint.ResumePositionMs) * time.Millisecond,
+			played:   e.ResumePoint.FullyPlayed,
+		}
+		if e.ReleaseDate != "" {
+			it.addedAt = e.ReleaseDateTime()
+		}
+		itemList = append(itemList, it)
 	}
 	return itemList
 }
@@ -469,16 +1452,35 @@ func episodesToItemList(episodes []spotify.EpisodePage) []list.Item {
 func albumTracksToItemList(tracks []spotify.SimpleTrack) []list.Item {
 	var itemList []list.Item
 	for _, t := range tracks {
-		itemList = append(itemList, item(t.Name))
+		itemList = append(itemList, simpleTrackToItem(t))
 	}
 	return itemList
 }
 
+func simpleTrackToItem(t spotify.SimpleTrack) item {
+	var artists []string
+	for _, a := range t.Artists {
+		artists = append(artists, a.Name)
+	}
+	return item{
+		title:    t.Name,
+		artist:   strings.Join(artists, ", "),
+		duration: t.TimeDuration(),
+		explicit: t.Explicit,
+	}
+}
+
+func fullTrackToItem(t spotify.FullTrack) item {
+	it := simpleTrackToItem(t.SimpleTrack)
+	it.album = t.Album.Name
+	return it
+}
+
 func albumToItemList(albums *spotify.SavedAlbumPage) []list.Item {
 	// TODO:added_atでソート
 	var itemList []list.Item
 	for _, a := range albums.Albums {
-		itemList = append(itemList, item(a.Name))
+		itemList = append(itemList, item{title: a.Name, uri: a.URI})
 	}
 	return itemList
 }
@@ -486,7 +1488,7 @@ func albumToItemList(albums *spotify.SavedAlbumPage) []list.Item {
 func showsToItemList(shows *spotify.SavedShowPage) []list.Item {
 	var itemList []list.Item
 	for _, s := range shows.Shows {
-		itemList = append(itemList, item(s.Name))
+		itemList = append(itemList, item{title: s.Name, uri: s.URI})
 	}
 	return itemList
 }
@@ -494,7 +1496,7 @@ func showsToItemList(shows *spotify.SavedShowPage) []list.Item {
 func playlistsToItemList(playlist *spotify.SimplePlaylistPage) []list.Item {
 	var itemList []list.Item
 	for _, p := range playlist.Playlists {
-		itemList = append(itemList, item(p.Name))
+		itemList = append(itemList, item{title: p.Name, uri: p.URI})
 	}
 	return itemList
 }
@@ -507,8 +1509,9 @@ func tabBorderWithBottom(left, middle, right string) lipgloss.Border {
 	return border
 }
 
-func paddingTabBorder() string {
-	style := activeTabStyle.Copy()
+// paddingTabBorder fills the space right of the tabs up to width.
+func paddingTabBorder(s Styles, width int) string {
+	style := s.activeTab.Copy()
 	border, _, _, _, _ := style.GetBorder()
 	border.Bottom = "─"
 	border.BottomLeft = "─"
@@ -521,13 +1524,18 @@ func paddingTabBorder() string {
 	border.Left = ""
 	border.Right = ""
 
-	style = style.Border(border)
-	return style.Render(strings.Repeat(" ", 8))
+	style = style.Border(border).Padding(0)
+	gap := width - lipgloss.Width(style.Render(""))
+	return style.Render(strings.Repeat(" ", max(gap, 0)))
 }
 
 func (m TabModel) View() string {
 
 	if !m.authorized {
+		if m.loginPaste != nil {
+			return "Paste the URL you were redirected to, or the code in it:\n" +
+				m.textInput.textInput.View() + "\n"
+		}
 		return ""
 	}
 
@@ -556,9 +1564,12 @@ func textInputView(m TabModel) string {
 	case INPUT:
 		return "\n" + m.textInput.ViewText(m.textMode)
 	case ERROR:
-		return "\n" + errStyle.Render(m.textInput.ViewText(m.textMode))
+		return "\n" + m.styles.err.Render(m.textInput.ViewText(m.textMode))
 	case NONE:
-		return helpStyle.Render(m.help.View())
+		if m.layout.compact {
+			return ""
+		}
+		return "\n" + m.styles.list.HelpStyle.Render(m.help.View())
 	default:
 		return ""
 	}
@@ -566,16 +1577,20 @@ func textInputView(m TabModel) string {
 
 func tracksView(m TabModel) string {
 	doc := strings.Builder{}
-	windowStyleDtl := lipgloss.NewStyle().
-		BorderForeground(highlightColor).
-		Padding(1, 5).
-		Align(lipgloss.Left).
-		Border(lipgloss.RoundedBorder())
-
 	doc.WriteString(
-		windowStyleDtl.
+		m.windowStyle(m.styles.trackWindow).
 			Render(m.listView.View(m.depth)))
-	return docStyle.Render(doc.String())
+	return m.docStyle().Render(doc.String())
+}
+
+// windowStyle sizes a window style to the layout.
+func (m TabModel) windowStyle(style lipgloss.Style) lipgloss.Style {
+	l := m.layout
+	return style.Copy().Padding(l.winPadY, l.winPadX).Width(l.windowWidth())
+}
+
+func (m TabModel) docStyle() lipgloss.Style {
+	return m.styles.doc.Copy().Padding(m.layout.docPadY, m.layout.docPadX)
 }
 
 func tabView(m TabModel) string {
@@ -587,9 +1602,9 @@ func tabView(m TabModel) string {
 		var style lipgloss.Style
 		isFirst, isLast, isActive := i == 0, i == len(m.tabs)-1, i == m.activeTab
 		if isActive {
-			style = activeTabStyle.Copy()
+			style = m.styles.activeTab.Copy()
 		} else {
-			style = inactiveTabStyle.Copy()
+			style = m.styles.inactiveTab.Copy()
 		}
 		border, _, _, _, _ := style.GetBorder()
 		if isFirst && isActive {
@@ -602,34 +1617,142 @@ func tabView(m TabModel) string {
 			border.BottomRight = "┴"
 		}
 		style = style.Border(border)
+		if m.layout.compact {
+			style = style.Padding(0)
+			if !isActive {
+				t = string([]rune(t)[:3])
+			}
+		}
 		renderedTabs = append(renderedTabs, style.Render(t))
 	}
 
-	renderedTabs = append(renderedTabs, paddingTabBorder())
+	window := m.windowStyle(m.styles.window).
+		Render(m.tabContents[m.activeTab].View(m.depth))
 	row := lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
+	row = lipgloss.JoinHorizontal(lipgloss.Top, row,
+		paddingTabBorder(m.styles, lipgloss.Width(window)-lipgloss.Width(row)))
 	doc.WriteString(row)
 	doc.WriteString("\n")
-	doc.WriteString(
-		windowStyle.
-			Render(m.tabContents[m.activeTab].View(m.depth)))
+	doc.WriteString(window)
+
+	return m.docStyle().Render(doc.String())
+}
+
+func NewTabModel(opts ...TabModelOpt) TabModel {
+	tabs := []string{"Playlist", "Album", "Podcast", "Search"}
+
+	profile, _ := lookupProfile(nil, DefaultProfile)
+	m := TabModel{
+		tabs:      tabs,
+		depth:     TOP,
+		detail:    NODETAIL,
+		textInput: NewTextModel(),
+		help:      NewHelp(DefaultKeyMap()),
+		styles:    defaultStyles,
+		layout:    defaultLayout,
+		tokens:    fileStore{},
+		profile:   profile,
+	}
+
+	for _, opt := range opts {
+		opt(&m)
+	}
+	if !m.offline {
+		m.cache = newLibraryCache(m.profile.Name)
+	}
+	m.resetLists()
+	return m
+}
 
-	return docStyle.Render(doc.String())
+// resetLists replaces every list with an empty one waiting for its items.
+func (m *TabModel) resetLists() {
+	m.tabContents = []ListModel{
+		m.newListModel([]list.Item{item{title: loading}}),
+		m.newListModel([]list.Item{item{title: loading}}),
+		m.newListModel([]list.Item{item{title: loading}}),
+		m.newListModel([]list.Item{item{title: searchHint}}),
+	}
+	m.listView = m.newListModel(nil)
+}
+
+// activeList returns the list that keys go to, if it has loaded.
+func (m TabModel) activeList() (ListModel, bool) {
+	if m.depth > TOP {
+		return m.listView, true
+	}
+	return m.tabContents[m.activeTab], m.tabLoaded()
 }
 
-func NewTabModel() TabModel {
-	tabs := []string{"Playlist", "Album", "Podcast"}
-	listModels := []ListModel{
-		NewListModel([]list.Item{item(loading)}),
-		NewListModel([]list.Item{item(loading)}),
-		NewListModel([]list.Item{item(loading)}),
+// updateActiveList passes msg to the list that keys go to.
+func (m TabModel) updateActiveList(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if m.depth > TOP {
+		m.listView, cmd = m.listView.UpdateList(msg, m.help.KeyMap)
+	} else {
+		m.tabContents[m.activeTab], cmd = m.tabContents[m.activeTab].UpdateList(msg, m.help.KeyMap)
+	}
+	return m, cmd
+}
+
+// newListModel creates a list in the model's theme.
+func (m TabModel) newListModel(items []list.Item, opts ...ListModelOpt) ListModel {
+	return NewListModel(items, append([]ListModelOpt{
+		WithStyles(m.styles),
+		WithSize(m.layout.listWidth, m.layout.listHeight),
+	}, opts...)...)
+}
+
+type TabModelOpt func(*TabModel)
+
+// WithBackend skips the login flow and drives the model with b instead of
+// the Spotify Web API.
+func WithBackend(b Backend) TabModelOpt {
+	return func(m *TabModel) {
+		m.client = b
+		m.offline = true
 	}
+}
+
+// WithConfig applies the user's config file.
+func WithConfig(cfg Config) TabModelOpt {
+	return func(m *TabModel) {
+		m.help.KeyMap = cfg.KeyMap
+		m.login = cfg.Login
+		m.profiles = cfg.Profiles
+		m.player = cfg.Player
+		m.profile, _ = cfg.Profile(m.profile.Name)
+		if cfg.Theme.Name != "" {
+			WithTheme(cfg.Theme)(m)
+		}
+	}
+}
+
+// WithProfile logs in as p instead of the default profile.
+func WithProfile(p Profile) TabModelOpt {
+	return func(m *TabModel) {
+		m.profile = p
+	}
+}
+
+// WithTokenStore keeps tokens in s instead of plaintext files.
+func WithTokenStore(s TokenStore) TabModelOpt {
+	return func(m *TabModel) {
+		m.tokens = s
+	}
+}
+
+// WithPlayerObserver has o told about every player state.
+func WithPlayerObserver(o PlayerObserver) TabModelOpt {
+	return func(m *TabModel) {
+		m.observers = append(m.observers, o)
+	}
+}
 
-	return TabModel{
-		tabs:        tabs,
-		tabContents: listModels,
-		depth:       TOP,
-		textInput:   NewTextModel(),
-		help:        NewHelp(),
+// WithTheme restyles the whole UI with t.
+func WithTheme(t Theme) TabModelOpt {
+	return func(m *TabModel) {
+		m.styles = NewStyles(t)
+		m.help.help.Styles = m.styles.help
 	}
 }
 
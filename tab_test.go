@@ -0,0 +1,381 @@
+package sptui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmb3/spotify/v2"
+)
+
+// cmdTimeout is how long a command may take before it is taken for a tick
+// and dropped. It is shorter than the quickest tick, the 100ms step of the
+// title animation, and requests to the fake backend return at once.
+const cmdTimeout = 50 * time.Millisecond
+
+// update passes each of msgs to m, followed by every message that its
+// commands produce, apart from ticks.
+func update(t *testing.T, m tea.Model, msgs ...tea.Msg) tea.Model {
+	t.Helper()
+	for _, msg := range msgs {
+		m = settle(t, m, msg)
+	}
+	return m
+}
+
+func settle(t *testing.T, m tea.Model, msg tea.Msg) tea.Model {
+	t.Helper()
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		msg := queue[0]
+		queue = queue[1:]
+		switch msg := msg.(type) {
+		case nil:
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				queue = append(queue, runCmd(cmd))
+			}
+		case ErrMsg:
+			t.Fatalf("unexpected error: %v", msg.Err)
+		default:
+			var cmd tea.Cmd
+			m, cmd = m.Update(msg)
+			queue = append(queue, runCmd(cmd))
+		}
+	}
+	return m
+}
+
+func runCmd(cmd tea.Cmd) tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msgs := make(chan tea.Msg, 1)
+	go func() { msgs <- cmd() }()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(cmdTimeout):
+		return nil
+	}
+}
+
+func keyPress(s string) tea.KeyMsg {
+	switch s {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func keyPresses(keys ...string) []tea.Msg {
+	msgs := make([]tea.Msg, len(keys))
+	for i, k := range keys {
+		msgs[i] = keyPress(k)
+	}
+	return msgs
+}
+
+// newTestModel returns a model logged in to a fake backend, with the
+// library loaded.
+func newTestModel(t *testing.T) (tea.Model, *FakeBackend) {
+	t.Helper()
+	f := NewFakeBackend()
+	m := update(t, NewTabModel(WithBackend(f)), AuthMsg{f})
+	return m, f
+}
+
+func playingURI(t *testing.T, f *FakeBackend) spotify.URI {
+	t.Helper()
+	cp, err := f.PlayerCurrentlyPlaying(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Item == nil {
+		t.Fatal("nothing is playing")
+	}
+	return cp.Item.URI
+}
+
+func TestOpenAlbumAndPlay(t *testing.T) {
+	m, f := newTestModel(t)
+	// Album tab, first album, third track.
+	m = update(t, m, keyPresses("l", "enter", "down", "down", "enter")...)
+
+	tm := m.(TabModel)
+	if tm.depth != TRACKLIST || tm.selectedAlbum == nil || tm.selectedAlbum.ID != "fakealbum1" {
+		t.Fatalf("got depth %d; want the tracklist of fakealbum1", tm.depth)
+	}
+	if got, want := playingURI(t, f), spotify.URI("spotify:track:fakealbum1t3"); got != want {
+		t.Errorf("playing %s, want %s", got, want)
+	}
+}
+
+func TestKeysBeforeTracklistLoads(t *testing.T) {
+	m, f := newTestModel(t)
+	m = update(t, m, keyPresses("l", "enter")...)
+
+	// Open another album, but press keys before it has loaded.
+	m = update(t, m, keyPress("esc"))
+	m, _ = m.Update(keyPress("down"))
+	m, cmd := m.Update(keyPress("enter"))
+	if tm := m.(TabModel); tm.selectedAlbum != nil || tm.detail != NODETAIL {
+		t.Fatalf("the previous album is still selected while loading: detail %d", tm.detail)
+	}
+	for _, k := range []string{"enter", "a"} {
+		var keyCmd tea.Cmd
+		m, keyCmd = m.Update(keyPress(k))
+		if keyCmd != nil {
+			t.Errorf("%q on a loading tracklist returned a command", k)
+		}
+	}
+
+	update(t, m, runCmd(cmd))
+	queue, err := f.GetQueue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Items) != 0 {
+		t.Errorf("queued %d items, want none", len(queue.Items))
+	}
+}
+
+func TestEnqueueTrack(t *testing.T) {
+	m, f := newTestModel(t)
+	// Play the first track, then queue the second.
+	m = update(t, m, keyPresses("l", "enter", "enter", "down", "a")...)
+
+	queue, err := f.GetQueue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The queued track comes before the rest of the album.
+	if len(queue.Items) != 4 || queue.Items[0].URI != "spotify:track:fakealbum1t2" {
+		t.Errorf("queue is %v, want spotify:track:fakealbum1t2 followed by the album", queue.Items)
+	}
+}
+
+func TestFilterThenPlay(t *testing.T) {
+	m, f := newTestModel(t)
+	m = update(t, m, keyPresses("l", "enter")...)
+	m = update(t, m, keyPresses("/", "s", "o", "l", "a", "r", "enter", "enter")...)
+
+	if got, want := playingURI(t, f), spotify.URI("spotify:track:fakealbum1t3"); got != want {
+		t.Errorf("playing %s, want %s (Solar Wind)", got, want)
+	}
+}
+
+func TestLoadMorePlaylistTracks(t *testing.T) {
+	m, f := newTestModel(t)
+	// Everything Thrice is the third playlist.
+	m = update(t, m, keyPresses("down", "down", "enter")...)
+
+	tm := m.(TabModel)
+	if tm.selectedPlaylist == nil || tm.selectedPlaylist.ID != "fakeplaylist3" {
+		t.Fatal("fakeplaylist3 is not open")
+	}
+	total := tm.selectedPlaylist.Tracks.Total
+	if len(tm.selectedPlaylist.Tracks.Tracks) >= total {
+		t.Fatalf("the first page has all %d tracks", total)
+	}
+
+	for range total {
+		m = update(t, m, keyPress("down"))
+	}
+	tm = m.(TabModel)
+	if got := len(tm.selectedPlaylist.Tracks.Tracks); got != total {
+		t.Fatalf("loaded %d tracks, want %d", got, total)
+	}
+	if got := len(tm.listView.list.Items()); got != total {
+		t.Errorf("the list has %d tracks, want %d", got, total)
+	}
+
+	m = update(t, m, keyPress("enter"))
+	want := tm.selectedPlaylist.Tracks.Tracks[total-1].Track.URI
+	if got := playingURI(t, f); got != want {
+		t.Errorf("playing %s, want %s", got, want)
+	}
+}
+
+func TestLoadMoreFiltered(t *testing.T) {
+	m, _ := newTestModel(t)
+	m = update(t, m, keyPresses("down", "down", "enter")...)
+	// 始発電車 is both the 8th and the last track of the first page.
+	m = update(t, m, keyPresses("/", "始発", "enter", "down")...)
+
+	tm := m.(TabModel)
+	if got, want := len(tm.selectedPlaylist.Tracks.Tracks), tm.selectedPlaylist.Tracks.Total; got != want {
+		t.Errorf("loaded %d tracks, want all %d", got, want)
+	}
+}
+
+func TestRevalidateLibraryAway(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+	}{
+		{"tracklist open", []string{"l", "enter"}},
+		{"typing a command", []string{"l", ":"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeBackend()
+			fresh, err := f.CurrentUsersAlbums(context.Background(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stale := *fresh
+			stale.Albums = fresh.Albums[:1]
+
+			// Move away from the cached albums before the Web API answers.
+			m, _ := NewTabModel(WithBackend(f)).Update(AuthMsg{f})
+			m = update(t, m, AlbumMsg{Albums: &stale, Cached: true})
+			m = update(t, m, keyPresses(tt.keys...)...)
+			m = update(t, m, AlbumMsg{Albums: fresh})
+
+			tm := m.(TabModel)
+			if got, want := len(tm.albums.Albums), len(fresh.Albums); got != want {
+				t.Errorf("the tab has %d albums, want %d", got, want)
+			}
+			if tm.tabContents[ALBUM].Fetching {
+				t.Error("paging is still off")
+			}
+		})
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	f := NewFakeBackend()
+	// One more album than fits on a page of results.
+	for i := range searchLimit + 1 {
+		id := spotify.ID(fmt.Sprintf("echo%d", i))
+		f.AddAlbum(spotify.FullAlbum{SimpleAlbum: spotify.SimpleAlbum{
+			ID:      id,
+			URI:     "spotify:album:" + spotify.URI(id),
+			Name:    fmt.Sprintf("Echo %d", i),
+			Artists: []spotify.SimpleArtist{{Name: "The Repeats"}},
+		}})
+	}
+	m := update(t, NewTabModel(WithBackend(f)), AuthMsg{f})
+	search := []tea.Msg{keyPress(":"), keyPress("search echo"), keyPress("enter")}
+	m = update(t, m, search...)
+	first := len(m.(TabModel).search.entries)
+
+	// The next page arrives after the device list was opened.
+	m, cmd := m.Update(LoadMoreMsg{})
+	m = update(t, m, keyPress("d"))
+	m = update(t, m, runCmd(cmd))
+	tm := m.(TabModel)
+	if len(tm.search.entries) <= first {
+		t.Errorf("the second page was dropped: %d results", len(tm.search.entries))
+	}
+	if tm.tabContents[SEARCH].Fetching {
+		t.Error("paging is still off")
+	}
+
+	// A page of an earlier search for the same query is dropped.
+	m, cmd = m.Update(LoadMoreMsg{})
+	m = update(t, m, keyPress("esc"))
+	m = update(t, m, search...)
+	m = update(t, m, runCmd(cmd))
+	if got := len(m.(TabModel).search.entries); got != first {
+		t.Errorf("got %d results, want the %d of the first page", got, first)
+	}
+}
+
+func TestPlayingWithoutDuration(t *testing.T) {
+	m, _ := newTestModel(t)
+	// Such as a local file.
+	m, _ = m.Update(PlayerStateMsg{State: &spotify.PlayerState{CurrentlyPlaying: spotify.CurrentlyPlaying{
+		Playing:  true,
+		Progress: 5000,
+		Item:     &spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{Name: "Demo Tape", URI: "spotify:local:demo"}},
+	}}})
+
+	tm := m.(TabModel)
+	if tm.progress.percent != 0 || tm.progress.PositionMs() != 0 {
+		t.Errorf("the bar is at %v, %dms; want empty", tm.progress.percent, tm.progress.PositionMs())
+	}
+	if _, cmd := seekBy(tm, seekStep); cmd != nil {
+		t.Error("seeking in an item without a duration")
+	}
+}
+
+func TestPollAfterLogin(t *testing.T) {
+	playing := func(name string) *spotify.PlayerState {
+		return &spotify.PlayerState{CurrentlyPlaying: spotify.CurrentlyPlaying{
+			Playing: true,
+			Item:    &spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{Name: name, Duration: 200000}},
+		}}
+	}
+	tests := []struct {
+		name string
+		msg  tea.Msg
+		// cmd is whether the poll goes on.
+		cmd bool
+		// want is the title shown afterwards, or empty if nothing is.
+		want string
+	}{
+		{name: "tick", msg: pollTickMsg{id: 2}, cmd: true},
+		{name: "stale tick", msg: pollTickMsg{id: 1}},
+		{name: "state", msg: pollStateMsg{id: 2, state: playing("Solar Wind")}, cmd: true, want: "Solar Wind"},
+		{name: "stale state", msg: pollStateMsg{id: 1, state: playing("Polar Night")}},
+		{name: "failed", msg: pollStateMsg{id: 2, err: errors.New("bad gateway")}, cmd: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, f := newTestModel(t)
+			// Logging in again leaves the polling of the first login behind.
+			m, _ = m.Update(ErrMsg{Err: ErrTokenRevoked})
+			m = update(t, m, AuthMsg{f})
+			if id := m.(TabModel).pollID; id != 2 {
+				t.Fatalf("pollID is %d after two logins", id)
+			}
+
+			m, cmd := m.Update(tt.msg)
+			if (cmd != nil) != tt.cmd {
+				t.Errorf("got command %v, want %v", cmd != nil, tt.cmd)
+			}
+			got := ""
+			if cp := m.(TabModel).currentlyPlaying; cp != nil && cp.Item != nil {
+				got = cp.Item.Name
+			}
+			if got != tt.want {
+				t.Errorf("showing %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"83", 83 * time.Second, true},
+		{"1:23", 83 * time.Second, true},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, true},
+		{"0:90", 90 * time.Second, true},
+		{"", 0, false},
+		{"1:", 0, false},
+		{"-5", 0, false},
+		{"1:-5", 0, false},
+		{"1.5", 0, false},
+		{"1:2:3:4", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parsePosition(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parsePosition(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
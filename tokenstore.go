@@ -0,0 +1,241 @@
+package sptui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"golang.org/x/oauth2"
+)
+
+// Token store names for the store key of the [token] section.
+const (
+	TokenStoreFile      = "file"
+	TokenStoreKeyring   = "keyring"
+	TokenStoreEncrypted = "encrypted"
+)
+
+const encryptedTokenFile = "spotify_token.age"
+
+// TokenConfig is the [token] section of the config file.
+type TokenConfig struct {
+	// Store is where tokens are kept: "file" (the default), "keyring" for
+	// the Secret Service, or "encrypted" for a passphrase-protected file.
+	Store string `toml:"store"`
+}
+
+func (c TokenConfig) validate() error {
+	switch c.Store {
+	case "", TokenStoreFile, TokenStoreKeyring, TokenStoreEncrypted:
+		return nil
+	}
+	return fmt.Errorf("unknown token store %q: use %s, %s or %s",
+		c.Store, TokenStoreFile, TokenStoreKeyring, TokenStoreEncrypted)
+}
+
+// TokenStore keeps the OAuth token of each profile. Load and Delete return an
+// error wrapping fs.ErrNotExist when the profile has no token.
+type TokenStore interface {
+	Load(profile string) (*oauth2.Token, error)
+	Save(profile string, token *oauth2.Token) error
+	Delete(profile string) error
+	// Profiles lists the profiles with a saved token.
+	Profiles() ([]string, error)
+}
+
+// NewTokenStore returns the store chosen in cfg. passphrase is only called
+// for the encrypted store.
+func NewTokenStore(cfg TokenConfig, passphrase func() (string, error)) (TokenStore, error) {
+	switch cfg.Store {
+	case "", TokenStoreFile:
+		return fileStore{}, nil
+	case TokenStoreKeyring:
+		return keyringStore{}, nil
+	case TokenStoreEncrypted:
+		p, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if p == "" {
+			return nil, errors.New("the token passphrase is empty")
+		}
+		return encryptedStore{passphrase: p}, nil
+	}
+	return nil, cfg.validate()
+}
+
+// migrateToken moves a plaintext token of profile into store, so that
+// switching to another store does not require logging in again.
+func migrateToken(store TokenStore, profile string) error {
+	if _, ok := store.(fileStore); ok {
+		return nil
+	}
+	plain := fileStore{}
+	token, err := plain.Load(profile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := store.Save(profile, token); err != nil {
+		return fmt.Errorf("migrating token: %w", err)
+	}
+	return plain.Delete(profile)
+}
+
+// tokenFile returns the path of a token file called base for profile. The
+// default profile keeps its token next to the config file, where it was
+// before profiles existed.
+func tokenFile(profile, base string) string {
+	homeDir, _ := os.UserHomeDir()
+	if profile == DefaultProfile {
+		return filepath.Join(homeDir, filepath.Dir(tokenFilePath), base)
+	}
+	return filepath.Join(homeDir, profilesDirPath, profile, base)
+}
+
+// profilesWithFile lists the profiles that have a token file called base.
+func profilesWithFile(base string) ([]string, error) {
+	homeDir, _ := os.UserHomeDir()
+	entries, err := os.ReadDir(filepath.Join(homeDir, profilesDirPath))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	candidates := []string{DefaultProfile}
+	for _, e := range entries {
+		if e.IsDir() && validProfileName.MatchString(e.Name()) {
+			candidates = append(candidates, e.Name())
+		}
+	}
+
+	var names []string
+	for _, name := range candidates {
+		if _, err := os.Stat(tokenFile(name, base)); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func writeTokenFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// removeTokenFile deletes a token file and the profile directory holding it
+// once it is empty.
+func removeTokenFile(profile, base string) error {
+	path := tokenFile(profile, base)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if profile != DefaultProfile {
+		os.Remove(filepath.Dir(path))
+	}
+	return nil
+}
+
+func decodeToken(data []byte) (*oauth2.Token, error) {
+	var token oauth2.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// fileStore keeps tokens as plaintext JSON.
+type fileStore struct{}
+
+func (fileStore) Load(profile string) (*oauth2.Token, error) {
+	data, err := os.ReadFile(tokenFile(profile, filepath.Base(tokenFilePath)))
+	if err != nil {
+		return nil, err
+	}
+	return decodeToken(data)
+}
+
+func (fileStore) Save(profile string, token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return writeTokenFile(tokenFile(profile, filepath.Base(tokenFilePath)), data)
+}
+
+func (fileStore) Delete(profile string) error {
+	return removeTokenFile(profile, filepath.Base(tokenFilePath))
+}
+
+func (fileStore) Profiles() ([]string, error) {
+	return profilesWithFile(filepath.Base(tokenFilePath))
+}
+
+// encryptedStore keeps tokens in files encrypted with age under a
+// passphrase.
+type encryptedStore struct {
+	passphrase string
+}
+
+func (s encryptedStore) Load(profile string) (*oauth2.Token, error) {
+	path := tokenFile(profile, encryptedTokenFile)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	identity, err := age.NewScryptIdentity(s.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(f, identity)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s (wrong passphrase?): %w", path, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	return decodeToken(data)
+}
+
+func (s encryptedStore) Save(profile string, token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	recipient, err := age.NewScryptRecipient(s.passphrase)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return writeTokenFile(tokenFile(profile, encryptedTokenFile), buf.Bytes())
+}
+
+func (encryptedStore) Delete(profile string) error {
+	return removeTokenFile(profile, encryptedTokenFile)
+}
+
+func (encryptedStore) Profiles() ([]string, error) {
+	return profilesWithFile(encryptedTokenFile)
+}
@@ -0,0 +1,63 @@
+package sptui
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	token := &oauth2.Token{AccessToken: "acc3ss", TokenType: "Bearer", RefreshToken: "r3fresh", Expiry: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name    string
+		profile string
+		// load is the passphrase the token is loaded with.
+		load string
+		// err is part of the expected error, or empty if there is none.
+		err string
+	}{
+		{name: "default profile", profile: DefaultProfile, load: "hunter2"},
+		{name: "other profile", profile: "work", load: "hunter2"},
+		{name: "wrong passphrase", profile: DefaultProfile, load: "hunter3", err: "wrong passphrase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			if err := (encryptedStore{passphrase: "hunter2"}).Save(tt.profile, token); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := (fileStore{}).Load(tt.profile); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("the token is in plaintext too: %v", err)
+			}
+
+			store := encryptedStore{passphrase: tt.load}
+			got, err := store.Load(tt.profile)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %+v, %v; want error %s", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.AccessToken != token.AccessToken || got.RefreshToken != token.RefreshToken || !got.Expiry.Equal(token.Expiry) {
+				t.Errorf("loaded %+v, want %+v", got, token)
+			}
+			if profiles, err := store.Profiles(); err != nil || len(profiles) != 1 || profiles[0] != tt.profile {
+				t.Errorf("profiles are %q, %v; want %s", profiles, err, tt.profile)
+			}
+
+			if err := store.Delete(tt.profile); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Load(tt.profile); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("loading a deleted token: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package sptui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetries is how many times a failed request is sent again.
+	maxRetries = 3
+	// retryDelay is the backoff before the first retry, doubled each time.
+	retryDelay = 500 * time.Millisecond
+	// maxRetryAfter is the longest Retry-After that is waited out. Beyond
+	// it the rate limit is reported instead of freezing the request, which
+	// would run into requestTimeout anyway.
+	maxRetryAfter = 10 * time.Second
+)
+
+// apiTransport sends Web API requests through base. Requests that are
+// rate limited or fail with a server or network error are retried after
+// Retry-After or a jittered backoff, and identical GET requests in flight
+// at the same time share one response.
+type apiTransport struct {
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// inflight holds the shared GET requests by URL.
+	inflight map[string]*sharedCall
+}
+
+func newAPITransport(base http.RoundTripper) *apiTransport {
+	return &apiTransport{base: base, inflight: map[string]*sharedCall{}}
+}
+
+// sharedCall is a GET request made for every request waiting for it. It is
+// cancelled once they have all given up.
+type sharedCall struct {
+	done    chan struct{}
+	resp    sharedResponse
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (t *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Body != nil {
+		return t.retry(req)
+	}
+
+	key := req.URL.String()
+	t.mu.Lock()
+	call, ok := t.inflight[key]
+	if !ok {
+		// One caller giving up must not cancel the request for the others,
+		// so it gets a context and timeout of its own.
+		ctx, cancel := request(context.WithoutCancel(req.Context()))
+		call = &sharedCall{done: make(chan struct{}), cancel: cancel}
+		t.inflight[key] = call
+		go t.share(key, call, req.WithContext(ctx))
+	}
+	call.waiters++
+	t.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.resp.copyFor(req), nil
+	case <-req.Context().Done():
+		t.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			t.forget(key, call)
+		}
+		t.mu.Unlock()
+		return nil, req.Context().Err()
+	}
+}
+
+// share makes the shared request and reads the response for its waiters.
+func (t *apiTransport) share(key string, call *sharedCall, req *http.Request) {
+	defer call.cancel()
+	resp, err := t.retry(req)
+	if err == nil {
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		call.resp = sharedResponse{resp, body}
+	}
+	call.err = err
+
+	t.mu.Lock()
+	t.forget(key, call)
+	t.mu.Unlock()
+	close(call.done)
+}
+
+// forget lets later requests for key make a new call. t.mu must be held.
+func (t *apiTransport) forget(key string, call *sharedCall) {
+	if t.inflight[key] == call {
+		delete(t.inflight, key)
+	}
+}
+
+// sharedResponse is a response read in full, so that every request that
+// shared it can read the body.
+type sharedResponse struct {
+	resp *http.Response
+	body []byte
+}
+
+func (s sharedResponse) copyFor(req *http.Request) *http.Response {
+	resp := *s.resp
+	resp.Header = s.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(s.body))
+	resp.Request = req
+	return &resp
+}
+
+// retry sends req until it succeeds, fails for good or runs out of
+// retries, and returns the last response.
+func (t *apiTransport) retry(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 {
+			r = req.Clone(req.Context())
+			if req.Body != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		wait, ok := retryAfter(req, resp, err, attempt)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryAfter reports whether a request that got resp or err should be
+// sent again, and how long to wait first.
+func retryAfter(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= maxRetries || req.Body != nil && req.GetBody == nil {
+		return 0, false
+	}
+	backoff := retryDelay << attempt
+	backoff = backoff/2 + rand.N(backoff/2)
+
+	// A rate limited request was not carried out, so even POST can be sent
+	// again. Other failures may have had an effect, so only requests that
+	// can be repeated safely are.
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			return backoff, true
+		}
+		return wait, wait <= maxRetryAfter
+	}
+	if !idempotent(req.Method) {
+		return 0, false
+	}
+	var netErr net.Error
+	switch {
+	case err != nil:
+		return backoff, errors.As(err, &netErr)
+	case resp.StatusCode == http.StatusInternalServerError,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return backoff, true
+	}
+	return 0, false
+}
+
+// parseRetryAfter reads a Retry-After header, which is in seconds or an
+// HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
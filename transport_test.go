@@ -0,0 +1,196 @@
+package sptui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// A date in the future is waited for until then.
+	date := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(date); !ok || got <= 3*time.Second || got > 5*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, %v; want about 5s", date, got, ok)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	response := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+	netErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name    string
+		method  string
+		body    bool
+		resp    *http.Response
+		err     error
+		attempt int
+		retry   bool
+		// wait is the exact delay, or zero for a jittered backoff.
+		wait time.Duration
+	}{
+		{name: "ok", method: http.MethodGet, resp: response(http.StatusOK, "")},
+		{name: "not found", method: http.MethodGet, resp: response(http.StatusNotFound, "")},
+		{name: "rate limited", method: http.MethodGet, resp: response(http.StatusTooManyRequests, "2"), retry: true, wait: 2 * time.Second},
+		{name: "rate limited without Retry-After", method: http.MethodGet, resp: response(http.StatusTooManyRequests, ""), retry: true},
+		{name: "rate limited for too long", method: http.MethodGet, resp: response(http.StatusTooManyRequests, "60")},
+		{name: "rate limited POST", method: http.MethodPost, body: true, resp: response(http.StatusTooManyRequests, "1"), retry: true, wait: time.Second},
+		{name: "server error", method: http.MethodGet, resp: response(http.StatusServiceUnavailable, ""), retry: true},
+		{name: "server error on PUT", method: http.MethodPut, resp: response(http.StatusBadGateway, ""), retry: true},
+		{name: "server error on POST", method: http.MethodPost, resp: response(http.StatusInternalServerError, "")},
+		{name: "network error", method: http.MethodGet, err: netErr, retry: true},
+		{name: "network error on POST", method: http.MethodPost, err: netErr},
+		{name: "other error", method: http.MethodGet, err: errors.New("bad request")},
+		{name: "out of retries", method: http.MethodGet, resp: response(http.StatusServiceUnavailable, ""), attempt: maxRetries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, "https://api.spotify.com/v1/me/player", nil)
+			if tt.body {
+				req, _ = http.NewRequest(tt.method, "https://api.spotify.com/v1/me/player/next", strings.NewReader("{}"))
+			}
+			wait, retry := retryAfter(req, tt.resp, tt.err, tt.attempt)
+			if retry != tt.retry {
+				t.Fatalf("retry = %v, want %v", retry, tt.retry)
+			}
+			if !retry {
+				return
+			}
+			backoff := retryDelay << tt.attempt
+			switch {
+			case tt.wait != 0 && wait != tt.wait:
+				t.Errorf("wait = %v, want %v", wait, tt.wait)
+			case tt.wait == 0 && (wait < backoff/2 || wait >= backoff):
+				t.Errorf("wait = %v, want between %v and %v", wait, backoff/2, backoff)
+			}
+		})
+	}
+}
+
+func TestRetryAfterBodyWithoutGetBody(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "https://api.spotify.com/v1/me/player", strings.NewReader("{}"))
+	req.GetBody = nil
+	if _, retry := retryAfter(req, &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}, nil, 0); retry {
+		t.Error("a body that cannot be sent again is retried")
+	}
+}
+
+func TestSharedRequest(t *testing.T) {
+	var requests, cancelled atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-release:
+			io.WriteString(w, "ok")
+		case <-r.Context().Done():
+			cancelled.Add(1)
+		}
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: newAPITransport(http.DefaultTransport)}
+
+	get := func(ctx context.Context) (string, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/me/player", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	waitFor := func(n *atomic.Int32, want int32) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); n.Load() != want; {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d, want %d", n.Load(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// The request goes on while anyone waits for it.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := get(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled request returned %v", err)
+		}
+	}()
+	results := make(chan string, 2)
+	for range 2 {
+		go func() {
+			body, err := get(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			results <- body
+		}()
+	}
+	waitFor(&requests, 1)
+	// Let the other requests join the first.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	wg.Wait()
+	close(release)
+	for range 2 {
+		if body := <-results; body != "ok" {
+			t.Errorf("got %q, want ok", body)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+
+	// It is cancelled once everyone has given up.
+	release = make(chan struct{})
+	requests.Store(0)
+	ctx, cancel = context.WithCancel(context.Background())
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(ctx)
+		}()
+	}
+	waitFor(&requests, 1)
+	cancel()
+	wg.Wait()
+	waitFor(&cancelled, 1)
+}
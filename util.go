@@ -50,7 +50,7 @@ func WrapText(s string, width int, line int) string {
 		ret += runewidth.Truncate(s, width, "")
 		s = runewidth.TruncateLeft(s, width, "")
 		cnt++
-		if length-cnt*listWidth <= 0 {
+		if length-cnt*width <= 0 {
 			break
 		}
 		ret += "\n"
@@ -59,9 +59,9 @@ func WrapText(s string, width int, line int) string {
 }
 
 func PadOrTruncate(s string, n int) string {
-	if runewidth.StringWidth(s) > listWidth {
+	if runewidth.StringWidth(s) > n {
 		return runewidth.Truncate(s, n, "")
 	} else {
-		return s + strings.Repeat(" ", listWidth-runewidth.StringWidth(s))
+		return s + strings.Repeat(" ", n-runewidth.StringWidth(s))
 	}
 }